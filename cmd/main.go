@@ -3,30 +3,127 @@ package main
 import (
 	//"os"
 
+	"flag"
+	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"time"
 
 	//"github.com/arslab/robot_controller/robot"
 
+	"github.com/arslab/robot_controller/codec"
+	"github.com/arslab/robot_controller/models"
 	"github.com/arslab/robot_controller/robot"
+	"github.com/arslab/robot_controller/utilities"
 	"github.com/arslab/robot_controller/webserver"
+	"github.com/fatih/color"
 )
 
 func main() {
 
-	c := make(chan os.Signal)
+	backend := flag.String("backend", "can", "robot backend: \"can\" for the SocketCAN interface, \"sim\" for the virtual robot, \"replay\" for a recorded CAN log")
+	networkInterface := flag.String("iface", "can0", "SocketCAN network interface used by the \"can\" backend")
+	simOpponent := flag.String("sim-opponent", "", "position \"x,y\" of a still opponent robot in the \"sim\" backend")
+	simStarter := flag.Duration("sim-starter", 0, "pull the starter of the \"sim\" backend the given time after it is enabled (0 never)")
+	replayFile := flag.String("replay", "", "candump log played by the \"replay\" backend")
+	replaySpeed := flag.Float64("replay-speed", 1, "speed factor of the \"replay\" backend (2 plays the log twice as fast)")
+	replayStepped := flag.Bool("replay-step", false, "play the frames of the \"replay\" backend only when requested through the API")
+	dbcFile := flag.String("dbc", "", "DBC file describing the robot frames (the built-in description is used if empty)")
+	matchDuration := flag.Duration("match-duration", robot.MATCH_DURATION, "duration of the match, the motors are stopped at its end")
+	fieldFile := flag.String("field", "", "JSON file describing the field layout (an empty 3000x2000 table is used if empty)")
+	batteryFile := flag.String("battery", "", "JSON file describing the battery discharge curve (a 4S LiPo pack is used if empty)")
+	recordDirectory := flag.String("record-dir", robot.RECORDER_DEFAULT_DIRECTORY, "directory of the CAN traffic logs (candump format)")
+	record := flag.Bool("record", false, "start recording the CAN traffic at startup")
+	fwToPointCommand := flag.Uint("cmd-fw-to-point", uint(models.MC_FW_TO_POINT), "code of the forward to point motion command")
+	flag.Parse()
+
+	if *fwToPointCommand > 0xFF {
+		log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), fmt.Sprintf("Invalid forward to point command: 0x%X", *fwToPointCommand))
+		os.Exit(1)
+	}
+	if err := codec.SetForwardToPointCommand(uint8(*fwToPointCommand)); err != nil {
+		log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
+		os.Exit(1)
+	}
+
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	signal.Notify(c, os.Kill)
 
-	time.Sleep(time.Second * 5)
+	//the recorded log lines carry the interface of the transport in use
+	var transport robot.Transport
+	var recordInterface string
+	switch *backend {
+	case "can":
+		time.Sleep(time.Second * 5)
+
+		canTransport, err := robot.NewSocketCANTransport(*networkInterface)
+		if err != nil {
+			log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
+			os.Exit(1)
+		}
+		transport = canTransport
+		recordInterface = *networkInterface
+	case "sim":
+		simTransport := robot.NewSimulatorTransport()
+		simTransport.StarterDelay = *simStarter
+		if *simOpponent != "" {
+			var opponent models.Position
+			if _, err := fmt.Sscanf(*simOpponent, "%d,%d", &opponent.X, &opponent.Y); err != nil {
+				log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), "Invalid opponent position: "+*simOpponent)
+				os.Exit(1)
+			}
+			simTransport.SetOpponent(&opponent)
+		}
+		transport = simTransport
+		recordInterface = "sim"
+	case "replay":
+		replayTransport, err := robot.NewReplayTransport(*replayFile, *replaySpeed, *replayStepped)
+		if err != nil {
+			log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
+			os.Exit(1)
+		}
+		transport = replayTransport
+		recordInterface = "replay"
+	default:
+		log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), "Unknown backend: "+*backend)
+		os.Exit(1)
+	}
 
-	robot, err := robot.NewRobot("can0")
+	robotInstance, err := robot.NewRobot(transport)
 	if err != nil {
 		os.Exit(1)
 	}
 
-	webServer := webserver.NewWebServer(robot, "0.0.0.0", 9998)
+	if *dbcFile != "" {
+		if err := robotInstance.LoadDBC(*dbcFile); err != nil {
+			os.Exit(1)
+		}
+	}
+
+	robotInstance.Match.SetDuration(*matchDuration)
+
+	if *fieldFile != "" {
+		if err := robotInstance.LoadField(*fieldFile); err != nil {
+			os.Exit(1)
+		}
+	}
+
+	if *batteryFile != "" {
+		if err := robotInstance.LoadBatteryConfig(*batteryFile); err != nil {
+			os.Exit(1)
+		}
+	}
+
+	robotInstance.Connection.Recorder.Configure(*recordDirectory, recordInterface)
+	if *record {
+		if err := robotInstance.Connection.Recorder.Start(); err != nil {
+			log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
+		}
+	}
+
+	webServer := webserver.NewWebServer(robotInstance, "0.0.0.0", 9998)
 
 	webServer.Start()
 
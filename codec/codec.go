@@ -0,0 +1,54 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	//FRAME_LENGTH is the length of every command and telemetry frame
+	FRAME_LENGTH = 8
+)
+
+var (
+	//ErrFrameLength is returned when the frame data has not the expected length
+	ErrFrameLength = errors.New("invalid frame length")
+	//ErrInvalidValue is returned when a value can not be encoded in the frame
+	ErrInvalidValue = errors.New("invalid value")
+	//ErrUnknownCommand is returned when the command code is not supported
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+//encode writes the fields of payload in little endian order into a FRAME_LENGTH bytes frame
+func encode(payload interface{}) ([]byte, error) {
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, payload); err != nil {
+		return nil, err
+	}
+	if buf.Len() > FRAME_LENGTH {
+		return nil, fmt.Errorf("%w: payload of %d bytes", ErrFrameLength, buf.Len())
+	}
+
+	data := make([]byte, FRAME_LENGTH)
+	copy(data, buf.Bytes())
+	return data, nil
+}
+
+//decode reads the fields of payload in little endian order from a FRAME_LENGTH bytes frame
+func decode(data []byte, payload interface{}) error {
+
+	if err := checkLength(data); err != nil {
+		return err
+	}
+	return binary.Read(bytes.NewReader(data), binary.LittleEndian, payload)
+}
+
+func checkLength(data []byte) error {
+	if len(data) != FRAME_LENGTH {
+		return fmt.Errorf("%w: %d bytes instead of %d", ErrFrameLength, len(data), FRAME_LENGTH)
+	}
+	return nil
+}
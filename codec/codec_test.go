@@ -0,0 +1,302 @@
+package codec_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/arslab/robot_controller/codec"
+	"github.com/arslab/robot_controller/dbc"
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/robot"
+)
+
+func TestMotionCommandRoundTrip(t *testing.T) {
+
+	tests := []models.MotionCommand{
+		{CMD: models.MC_STOP},
+		{CMD: models.MC_BRAKE},
+		{CMD: models.MC_SET_POSITION, PARAM_1: 1500, PARAM_2: -1000, PARAM_3: 90},
+		{CMD: models.MC_FW_TO_DISTANCE, PARAM_1: -32768, FLAGS: -1},
+		{CMD: models.MC_FW_TO_POINT, PARAM_1: 3000, PARAM_2: 2000, FLAGS: 1},
+		{CMD: models.MC_ROTATE_RELATIVE, PARAM_1: -180},
+		{CMD: models.MC_SET_SPEED, PARAM_1: 32767, PARAM_2: 200},
+	}
+
+	for _, cmd := range tests {
+		data, err := codec.EncodeMotionCommand(cmd)
+		if err != nil {
+			t.Fatalf("EncodeMotionCommand(%+v): %v", cmd, err)
+		}
+		if len(data) != codec.FRAME_LENGTH {
+			t.Errorf("EncodeMotionCommand(%+v): %d bytes", cmd, len(data))
+		}
+		decoded, err := codec.DecodeMotionCommand(data)
+		if err != nil {
+			t.Fatalf("DecodeMotionCommand(% X): %v", data, err)
+		}
+		if decoded != cmd {
+			t.Errorf("round trip of %+v returned %+v", cmd, decoded)
+		}
+	}
+}
+
+func TestStrategyCommandRoundTrip(t *testing.T) {
+
+	tests := []models.StrategyCommand{
+		{CMD: models.ST_ALIGN_PICCOLO},
+		{CMD: models.ST_ALIGN_GRANDE, FLAGS: 1},
+		{CMD: models.ST_ENABLE_STARTER, ELAPSED_TIME: 0},
+		{CMD: models.ST_DISABLE_STARTER, FLAGS: 255, ELAPSED_TIME: 100},
+	}
+
+	for _, cmd := range tests {
+		data, err := codec.EncodeStrategyCommand(cmd)
+		if err != nil {
+			t.Fatalf("EncodeStrategyCommand(%+v): %v", cmd, err)
+		}
+		decoded, err := codec.DecodeStrategyCommand(data)
+		if err != nil {
+			t.Fatalf("DecodeStrategyCommand(% X): %v", data, err)
+		}
+		if decoded != cmd {
+			t.Errorf("round trip of %+v returned %+v", cmd, decoded)
+		}
+	}
+}
+
+func TestTelemetryRoundTrip(t *testing.T) {
+
+	positions := []models.Position{
+		{X: 0, Y: 0, Angle: 0},
+		{X: 1500, Y: -1000, Angle: 90},
+		{X: -32768, Y: 32767, Angle: -180},
+		{X: 3000, Y: 2000, Angle: 180},
+	}
+	for _, position := range positions {
+		data, err := codec.EncodePosition(position)
+		if err != nil {
+			t.Fatalf("EncodePosition(%+v): %v", position, err)
+		}
+		decoded, err := codec.DecodePosition(data)
+		if err != nil || decoded != position {
+			t.Errorf("round trip of %+v returned %+v, %v", position, decoded, err)
+		}
+	}
+
+	for _, speed := range []int16{0, 1, -1, 500, math.MaxInt16, math.MinInt16} {
+		data, err := codec.EncodeSpeed(speed)
+		if err != nil {
+			t.Fatalf("EncodeSpeed(%d): %v", speed, err)
+		}
+		decoded, err := codec.DecodeSpeed(data)
+		if err != nil || decoded != speed {
+			t.Errorf("round trip of speed %d returned %d, %v", speed, decoded, err)
+		}
+	}
+
+	for _, status := range []uint16{0, 1, 0x0102, 0x8000, math.MaxUint16} {
+		data, err := codec.EncodeStatus(status)
+		if err != nil {
+			t.Fatalf("EncodeStatus(%d): %v", status, err)
+		}
+		decoded, err := codec.DecodeStatus(data)
+		if err != nil || decoded != status {
+			t.Errorf("round trip of status 0x%04X returned 0x%04X, %v", status, decoded, err)
+		}
+	}
+
+	obstacles := []models.ObstacleFrame{
+		{},
+		{Number: 3, Valid: 1, AngleStart: -30, AngleEnd: 30, Distance: 450},
+		{Number: 255, Valid: 0, AngleStart: math.MinInt16, AngleEnd: math.MaxInt16, Distance: -1},
+	}
+	for _, obstacle := range obstacles {
+		data, err := codec.EncodeObstacle(obstacle)
+		if err != nil {
+			t.Fatalf("EncodeObstacle(%+v): %v", obstacle, err)
+		}
+		decoded, err := codec.DecodeObstacle(data)
+		if err != nil || decoded != obstacle {
+			t.Errorf("round trip of %+v returned %+v, %v", obstacle, decoded, err)
+		}
+	}
+
+	batteries := []struct{ voltage, current float64 }{
+		{0, 0},
+		{16.8, 1.25},
+		{13.2, -2.5},
+		{65.535, 32.767},
+	}
+	for _, battery := range batteries {
+		data, err := codec.EncodeBattery(battery.voltage, battery.current)
+		if err != nil {
+			t.Fatalf("EncodeBattery(%v, %v): %v", battery.voltage, battery.current, err)
+		}
+		voltage, current, err := codec.DecodeBattery(data)
+		if err != nil || math.Abs(voltage-battery.voltage) > 1e-9 || math.Abs(current-battery.current) > 1e-9 {
+			t.Errorf("round trip of %v V %v A returned %v V %v A, %v", battery.voltage, battery.current, voltage, current, err)
+		}
+	}
+}
+
+func TestDecodeFrameLength(t *testing.T) {
+
+	decoders := map[string]func([]byte) error{
+		"motion command":   func(data []byte) error { _, err := codec.DecodeMotionCommand(data); return err },
+		"strategy command": func(data []byte) error { _, err := codec.DecodeStrategyCommand(data); return err },
+		"position":         func(data []byte) error { _, err := codec.DecodePosition(data); return err },
+		"speed":            func(data []byte) error { _, err := codec.DecodeSpeed(data); return err },
+		"status":           func(data []byte) error { _, err := codec.DecodeStatus(data); return err },
+		"obstacle":         func(data []byte) error { _, err := codec.DecodeObstacle(data); return err },
+		"battery":          func(data []byte) error { _, _, err := codec.DecodeBattery(data); return err },
+	}
+	lengths := []int{0, 1, codec.FRAME_LENGTH - 1, codec.FRAME_LENGTH + 1, 64}
+
+	for name, decode := range decoders {
+		for _, length := range lengths {
+			if err := decode(make([]byte, length)); !errors.Is(err, codec.ErrFrameLength) {
+				t.Errorf("%s of %d bytes: got %v, want ErrFrameLength", name, length, err)
+			}
+		}
+	}
+}
+
+func TestEncodeInvalidValues(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+		call func() error
+	}{
+		{"unknown motion command", codec.ErrUnknownCommand, func() error {
+			_, err := codec.EncodeMotionCommand(models.MotionCommand{CMD: 0x01})
+			return err
+		}},
+		{"unknown strategy command", codec.ErrUnknownCommand, func() error {
+			_, err := codec.EncodeStrategyCommand(models.StrategyCommand{CMD: 0x7F})
+			return err
+		}},
+		{"negative elapsed time", codec.ErrInvalidValue, func() error {
+			_, err := codec.EncodeStrategyCommand(models.StrategyCommand{CMD: models.ST_ALIGN_GRANDE, ELAPSED_TIME: -1})
+			return err
+		}},
+		{"oversize angle", codec.ErrInvalidValue, func() error {
+			_, err := codec.EncodePosition(models.Position{Angle: 400})
+			return err
+		}},
+		{"oversize voltage", codec.ErrInvalidValue, func() error {
+			_, err := codec.EncodeBattery(70, 0)
+			return err
+		}},
+		{"negative voltage", codec.ErrInvalidValue, func() error {
+			_, err := codec.EncodeBattery(-1, 0)
+			return err
+		}},
+		{"oversize current", codec.ErrInvalidValue, func() error {
+			_, err := codec.EncodeBattery(16, 40)
+			return err
+		}},
+		{"unknown decoded motion command", codec.ErrUnknownCommand, func() error {
+			_, err := codec.DecodeMotionCommand(make([]byte, codec.FRAME_LENGTH))
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		if err := test.call(); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestSetForwardToPointCommand(t *testing.T) {
+
+	defer codec.SetForwardToPointCommand(models.MC_FW_TO_POINT)
+
+	if err := codec.SetForwardToPointCommand(models.MC_FW_TO_DISTANCE); !errors.Is(err, codec.ErrInvalidValue) {
+		t.Errorf("code of another command: got %v, want ErrInvalidValue", err)
+	}
+	if err := codec.SetForwardToPointCommand(0x87); err != nil {
+		t.Fatal(err)
+	}
+	cmd := models.MotionCommand{CMD: 0x87, PARAM_1: 3000, PARAM_2: 2000}
+	data, err := codec.EncodeMotionCommand(cmd)
+	if err != nil {
+		t.Fatalf("EncodeMotionCommand(%+v): %v", cmd, err)
+	}
+	if decoded, err := codec.DecodeMotionCommand(data); err != nil || decoded != cmd {
+		t.Errorf("round trip of %+v returned %+v, %v", cmd, decoded, err)
+	}
+}
+
+//TestDecodePositionMatchesDBC checks that the codec and the DBC description decode the same pose
+func TestDecodePositionMatchesDBC(t *testing.T) {
+
+	database, err := dbc.Parse(strings.NewReader(robot.DEFAULT_DBC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, codec.FRAME_LENGTH)
+	for raw := math.MinInt16; raw <= math.MaxInt16; raw++ {
+		binary.LittleEndian.PutUint16(data[0:], uint16(int16(raw/7)))
+		binary.LittleEndian.PutUint16(data[2:], uint16(int16(-raw/11)))
+		binary.LittleEndian.PutUint16(data[4:], uint16(int16(raw)))
+
+		position, err := codec.DecodePosition(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, signals, err := database.Decode(robot.ID_ROBOT_POSITION, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromDBC := models.Position{
+			X:     int16(math.Round(signals["X"])),
+			Y:     int16(math.Round(signals["Y"])),
+			Angle: int16(math.Round(signals["ANGLE"])),
+		}
+		if position != fromDBC {
+			t.Fatalf("raw angle %d: codec %+v, DBC %+v", raw, position, fromDBC)
+		}
+	}
+}
+
+//TestDecodeStatusMatchesDBC checks that the status flags of the built-in DBC are the bits sent by the virtual robot
+func TestDecodeStatusMatchesDBC(t *testing.T) {
+
+	database, err := dbc.Parse(strings.NewReader(robot.DEFAULT_DBC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := map[string]uint16{
+		"MOVING":          models.STATUS_MOVING,
+		"TARGET_REACHED":  models.STATUS_TARGET_REACHED,
+		"BLOCKED":         models.STATUS_BLOCKED,
+		"ERROR":           models.STATUS_ERROR,
+		"STARTER_ENABLED": models.STATUS_STARTER_ENABLED,
+		"STARTER_PULLED":  models.STATUS_STARTER_PULLED,
+	}
+	for word := 0; word <= math.MaxUint16; word++ {
+		data, err := codec.EncodeStatus(uint16(word))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, signals, err := database.Decode(robot.ID_ROBOT_STATUS, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, mask := range flags {
+			if (signals[name] != 0) != (uint16(word)&mask != 0) {
+				t.Fatalf("status 0x%04X: %s = %v", word, name, signals[name])
+			}
+		}
+		if code := uint16(signals["ERROR_CODE"]); code != (uint16(word)&models.STATUS_ERROR_CODE_MASK)>>8 {
+			t.Fatalf("status 0x%04X: ERROR_CODE = %d", word, code)
+		}
+	}
+}
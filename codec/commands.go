@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+//EncodeMotionCommand returns the frame data of a motion command
+func EncodeMotionCommand(cmd models.MotionCommand) ([]byte, error) {
+	if err := checkMotionCommand(cmd.CMD); err != nil {
+		return nil, err
+	}
+	return encode(cmd)
+}
+
+//DecodeMotionCommand returns the motion command of the frame data
+func DecodeMotionCommand(data []byte) (models.MotionCommand, error) {
+	var cmd models.MotionCommand
+	if err := decode(data, &cmd); err != nil {
+		return models.MotionCommand{}, err
+	}
+	if err := checkMotionCommand(cmd.CMD); err != nil {
+		return models.MotionCommand{}, err
+	}
+	return cmd, nil
+}
+
+//EncodeStrategyCommand returns the frame data of a strategy command
+func EncodeStrategyCommand(cmd models.StrategyCommand) ([]byte, error) {
+	if err := checkStrategyCommand(cmd.CMD); err != nil {
+		return nil, err
+	}
+	if cmd.ELAPSED_TIME < 0 {
+		return nil, fmt.Errorf("%w: negative elapsed time %d", ErrInvalidValue, cmd.ELAPSED_TIME)
+	}
+	return encode(cmd)
+}
+
+//DecodeStrategyCommand returns the strategy command of the frame data
+func DecodeStrategyCommand(data []byte) (models.StrategyCommand, error) {
+	var cmd models.StrategyCommand
+	if err := decode(data, &cmd); err != nil {
+		return models.StrategyCommand{}, err
+	}
+	if err := checkStrategyCommand(cmd.CMD); err != nil {
+		return models.StrategyCommand{}, err
+	}
+	return cmd, nil
+}
+
+//SetForwardToPointCommand changes the code of the forward to point command,
+//it must be called before sending or receiving any frame
+func SetForwardToPointCommand(cmd uint8) error {
+	switch cmd {
+	case models.MC_STOP, models.MC_BRAKE, models.MC_SET_POSITION, models.MC_FW_TO_DISTANCE, models.MC_ROTATE_RELATIVE, models.MC_SET_SPEED:
+		return fmt.Errorf("%w: motion command 0x%02X is already used", ErrInvalidValue, cmd)
+	}
+	models.MC_FW_TO_POINT = cmd
+	return nil
+}
+
+func checkMotionCommand(cmd uint8) error {
+	switch cmd {
+	case models.MC_STOP, models.MC_BRAKE, models.MC_SET_POSITION, models.MC_FW_TO_DISTANCE, models.MC_FW_TO_POINT, models.MC_ROTATE_RELATIVE, models.MC_SET_SPEED:
+		return nil
+	}
+	return fmt.Errorf("%w: motion command 0x%02X", ErrUnknownCommand, cmd)
+}
+
+func checkStrategyCommand(cmd uint8) error {
+	switch cmd {
+	case models.ST_ALIGN_PICCOLO, models.ST_ALIGN_GRANDE, models.ST_ENABLE_STARTER, models.ST_DISABLE_STARTER:
+		return nil
+	}
+	return fmt.Errorf("%w: strategy command 0x%02X", ErrUnknownCommand, cmd)
+}
@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+const (
+	//ANGLE_SCALE is the factor applied to the angles sent in the position frames (hundredths of degree)
+	ANGLE_SCALE = 100
+	//BATTERY_SCALE is the factor applied to the voltage and current of the battery frames (mV and mA)
+	BATTERY_SCALE = 1000
+)
+
+//positionFrame rappresents the payload of a position frame
+type positionFrame struct {
+	X     int16
+	Y     int16
+	Angle int16
+}
+
+//statusFrame rappresents the payload of a status frame
+type statusFrame struct {
+	Reserved int16
+	Status   uint16
+}
+
+//batteryFrame rappresents the payload of a battery frame
+type batteryFrame struct {
+	Voltage uint16
+	Current int16
+}
+
+//EncodePosition returns the frame data of a robot position (own or opponent)
+func EncodePosition(position models.Position) ([]byte, error) {
+	angle := int(position.Angle) * ANGLE_SCALE
+	if angle > math.MaxInt16 || angle < math.MinInt16 {
+		return nil, fmt.Errorf("%w: angle %d out of range", ErrInvalidValue, position.Angle)
+	}
+	return encode(positionFrame{X: position.X, Y: position.Y, Angle: int16(angle)})
+}
+
+//DecodePosition returns the robot position (own or opponent) of the frame data
+func DecodePosition(data []byte) (models.Position, error) {
+	var frame positionFrame
+	if err := decode(data, &frame); err != nil {
+		return models.Position{}, err
+	}
+	//the angle is rounded to the nearest degree like the DBC decoding of the frame
+	angle := math.Round(float64(frame.Angle) / ANGLE_SCALE)
+	return models.Position{X: frame.X, Y: frame.Y, Angle: int16(angle)}, nil
+}
+
+//EncodeSpeed returns the frame data of the linear speed
+func EncodeSpeed(speed int16) ([]byte, error) {
+	return encode(speed)
+}
+
+//DecodeSpeed returns the linear speed of the frame data
+func DecodeSpeed(data []byte) (int16, error) {
+	var speed int16
+	if err := decode(data, &speed); err != nil {
+		return 0, err
+	}
+	return speed, nil
+}
+
+//EncodeStatus returns the frame data of the status word
+func EncodeStatus(status uint16) ([]byte, error) {
+	return encode(statusFrame{Status: status})
+}
+
+//DecodeStatus returns the status word of the frame data
+func DecodeStatus(data []byte) (uint16, error) {
+	var frame statusFrame
+	if err := decode(data, &frame); err != nil {
+		return 0, err
+	}
+	return frame.Status, nil
+}
+
+//EncodeObstacle returns the frame data of an obstacle map entry
+func EncodeObstacle(obstacle models.ObstacleFrame) ([]byte, error) {
+	return encode(obstacle)
+}
+
+//DecodeObstacle returns the obstacle map entry of the frame data
+func DecodeObstacle(data []byte) (models.ObstacleFrame, error) {
+	var obstacle models.ObstacleFrame
+	if err := decode(data, &obstacle); err != nil {
+		return models.ObstacleFrame{}, err
+	}
+	return obstacle, nil
+}
+
+//EncodeBattery returns the frame data of the battery voltage (V) and current (A)
+func EncodeBattery(voltage float64, current float64) ([]byte, error) {
+	millivolts := math.Round(voltage * BATTERY_SCALE)
+	milliamperes := math.Round(current * BATTERY_SCALE)
+	if millivolts < 0 || millivolts > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: voltage %.3f out of range", ErrInvalidValue, voltage)
+	}
+	if milliamperes > math.MaxInt16 || milliamperes < math.MinInt16 {
+		return nil, fmt.Errorf("%w: current %.3f out of range", ErrInvalidValue, current)
+	}
+	return encode(batteryFrame{Voltage: uint16(millivolts), Current: int16(milliamperes)})
+}
+
+//DecodeBattery returns the battery voltage (V) and current (A) of the frame data
+func DecodeBattery(data []byte) (float64, float64, error) {
+	var frame batteryFrame
+	if err := decode(data, &frame); err != nil {
+		return 0, 0, err
+	}
+	return float64(frame.Voltage) / BATTERY_SCALE, float64(frame.Current) / BATTERY_SCALE, nil
+}
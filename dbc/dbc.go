@@ -0,0 +1,295 @@
+package dbc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/arslab/robot_controller/utilities"
+	"github.com/fatih/color"
+)
+
+const (
+	//EXTENDED_ID_FLAG marks the extended (29 bit) identifiers, both in the DBC files and in the CAN frames
+	EXTENDED_ID_FLAG = 0x80000000
+	//EXTENDED_ID_MASK and STANDARD_ID_MASK are the identifier bits, without the frame flags
+	EXTENDED_ID_MASK = 0x1FFFFFFF
+	STANDARD_ID_MASK = 0x000007FF
+)
+
+var (
+	//ErrUnknownMessage is returned when decoding a frame that is not described by the DBC
+	ErrUnknownMessage = errors.New("message not described")
+	//ErrFrameLength is returned when decoding a frame shorter than the message length
+	ErrFrameLength = errors.New("frame shorter than the message")
+
+	//errExtendedMultiplexing is returned by the signals with extended multiplexing (mxM), they need the SG_MUL_VAL_ section
+	errExtendedMultiplexing = errors.New("extended multiplexing not supported")
+)
+
+//Database rappresents the messages described by a DBC file, keyed by frame identifier
+type Database struct {
+	Messages map[uint32]*Message
+}
+
+//Message rappresents a frame layout (BO_ line)
+type Message struct {
+	ID      uint32
+	Name    string
+	Length  uint8
+	Sender  string
+	Signals []*Signal
+}
+
+//Signal rappresents a value packed into a frame (SG_ line)
+type Signal struct {
+	Name           string
+	StartBit       int
+	Length         int
+	LittleEndian   bool
+	Signed         bool
+	Factor         float64
+	Offset         float64
+	Min            float64
+	Max            float64
+	Unit           string
+	Multiplexor    bool
+	MultiplexValue int //-1 if the signal is not multiplexed
+}
+
+var (
+	messageRegexp = regexp.MustCompile(`^BO_\s+(\d+)\s+(\w+)\s*:\s*(\d+)\s+(\w+)`)
+	signalRegexp  = regexp.MustCompile(`^SG_\s+(\w+)\s*(M|m\d+M?)?\s*:\s*(\d+)\|(\d+)@([01])([+-])\s*\(([^,]+),([^)]+)\)\s*\[([^|]+)\|([^\]]+)\]\s*"([^"]*)"`)
+)
+
+//ParseFile parses the given DBC file
+func ParseFile(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+//Parse reads the messages and signals of a DBC description, the other sections are ignored
+func Parse(r io.Reader) (*Database, error) {
+
+	db := Database{
+		Messages: make(map[uint32]*Message),
+	}
+
+	var current *Message
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(text, "BO_ "):
+			match := messageRegexp.FindStringSubmatch(text)
+			if match == nil {
+				return nil, fmt.Errorf("dbc line %d: invalid message", line)
+			}
+			id, _ := strconv.ParseUint(match[1], 10, 32)
+			length, _ := strconv.ParseUint(match[3], 10, 8)
+			if length > 8 {
+				return nil, fmt.Errorf("dbc line %d: message %s longer than 8 bytes", line, match[2])
+			}
+			current = &Message{
+				ID:     MessageID(uint32(id)),
+				Name:   match[2],
+				Length: uint8(length),
+				Sender: match[4],
+			}
+			db.Messages[current.ID] = current
+
+		case strings.HasPrefix(text, "SG_ "):
+			if current == nil {
+				return nil, fmt.Errorf("dbc line %d: signal without message", line)
+			}
+			signal, err := parseSignal(text)
+			if errors.Is(err, errExtendedMultiplexing) {
+				//the other signals of the message are still decoded
+				log.Printf("[%s] %s", utilities.CreateColorString("DBC", color.FgHiYellow), fmt.Sprintf("line %d: %s, signal ignored", line, err))
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("dbc line %d: %s", line, err)
+			}
+			if err := signal.check(current.Length); err != nil {
+				return nil, fmt.Errorf("dbc line %d: %s", line, err)
+			}
+			current.Signals = append(current.Signals, signal)
+
+		case text == "":
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &db, nil
+}
+
+func parseSignal(text string) (*Signal, error) {
+
+	match := signalRegexp.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("invalid signal")
+	}
+
+	signal := Signal{
+		Name:           match[1],
+		LittleEndian:   match[5] == "1",
+		Signed:         match[6] == "-",
+		Unit:           match[11],
+		MultiplexValue: -1,
+	}
+
+	if strings.HasPrefix(match[2], "m") && strings.HasSuffix(match[2], "M") {
+		return nil, fmt.Errorf("signal %s: %w", signal.Name, errExtendedMultiplexing)
+	} else if match[2] == "M" {
+		signal.Multiplexor = true
+	} else if match[2] != "" {
+		signal.MultiplexValue, _ = strconv.Atoi(match[2][1:])
+	}
+
+	var err error
+	if signal.StartBit, err = strconv.Atoi(match[3]); err != nil {
+		return nil, err
+	}
+	if signal.Length, err = strconv.Atoi(match[4]); err != nil {
+		return nil, err
+	}
+	values := []*float64{&signal.Factor, &signal.Offset, &signal.Min, &signal.Max}
+	for i, value := range values {
+		if *value, err = strconv.ParseFloat(strings.TrimSpace(match[7+i]), 64); err != nil {
+			return nil, fmt.Errorf("signal %s: %s", signal.Name, err)
+		}
+	}
+
+	return &signal, nil
+}
+
+//check verifies that the signal fits into a frame of the given length
+func (signal *Signal) check(length uint8) error {
+	if signal.Length < 1 || signal.Length > 64 {
+		return fmt.Errorf("signal %s: invalid length %d", signal.Name, signal.Length)
+	}
+	for _, bit := range signal.bits() {
+		if bit < 0 || bit >= int(length)*8 {
+			return fmt.Errorf("signal %s: out of the %d bytes message", signal.Name, length)
+		}
+	}
+	return nil
+}
+
+//bits returns the positions of the signal bits in the frame, from the most significant one
+func (signal *Signal) bits() []int {
+	bits := make([]int, signal.Length)
+	if signal.LittleEndian {
+		//the start bit is the least significant bit
+		for i := 0; i < signal.Length; i++ {
+			bits[signal.Length-1-i] = signal.StartBit + i
+		}
+		return bits
+	}
+
+	//motorola: the start bit is the most significant bit, bits go down in the byte and then to the next byte
+	bit := signal.StartBit
+	for i := 0; i < signal.Length; i++ {
+		bits[i] = bit
+		if bit%8 == 0 {
+			bit += 15
+		} else {
+			bit--
+		}
+	}
+	return bits
+}
+
+//Raw returns the unscaled value of the signal
+func (signal *Signal) Raw(data []byte) int64 {
+	var raw uint64
+	for _, bit := range signal.bits() {
+		raw <<= 1
+		if bit/8 < len(data) && data[bit/8]&(1<<uint(bit%8)) != 0 {
+			raw |= 1
+		}
+	}
+
+	if signal.Signed && signal.Length < 64 && raw&(1<<uint(signal.Length-1)) != 0 {
+		raw |= ^uint64(0) << uint(signal.Length)
+	}
+	return int64(raw)
+}
+
+//Decode returns the physical value of the signal (raw * factor + offset)
+func (signal *Signal) Decode(data []byte) float64 {
+	return float64(signal.Raw(data))*signal.Factor + signal.Offset
+}
+
+//Decode returns the physical value of every signal of the message.
+//The multiplexed signals are returned only if they match the multiplexor value.
+func (message *Message) Decode(data []byte) (map[string]float64, error) {
+
+	if len(data) < int(message.Length) {
+		return nil, fmt.Errorf("%w: %s has %d bytes, %d received", ErrFrameLength, message.Name, message.Length, len(data))
+	}
+
+	multiplex := int64(-1)
+	for _, signal := range message.Signals {
+		if signal.Multiplexor {
+			multiplex = signal.Raw(data)
+		}
+	}
+
+	values := make(map[string]float64, len(message.Signals))
+	for _, signal := range message.Signals {
+		if signal.MultiplexValue >= 0 && int64(signal.MultiplexValue) != multiplex {
+			continue
+		}
+		values[signal.Name] = signal.Decode(data)
+	}
+	return values, nil
+}
+
+//Signal returns the signal with the given name
+func (message *Message) Signal(name string) *Signal {
+	for _, signal := range message.Signals {
+		if signal.Name == name {
+			return signal
+		}
+	}
+	return nil
+}
+
+//Decode returns the message described for the frame identifier and its signal values,
+//data must contain only the received bytes of the frame
+func (db *Database) Decode(id uint32, data []byte) (*Message, map[string]float64, error) {
+	message, ok := db.Messages[MessageID(id)]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: 0x%X", ErrUnknownMessage, id)
+	}
+	values, err := message.Decode(data)
+	if err != nil {
+		return message, nil, err
+	}
+	return message, values, nil
+}
+
+//MessageID returns the identifier without the frame flags (RTR, error),
+//the extended identifiers keep EXTENDED_ID_FLAG so they don't match the standard ones
+func MessageID(id uint32) uint32 {
+	if id&EXTENDED_ID_FLAG != 0 {
+		return EXTENDED_ID_FLAG | id&EXTENDED_ID_MASK
+	}
+	return id & STANDARD_ID_MASK
+}
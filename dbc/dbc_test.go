@@ -0,0 +1,280 @@
+package dbc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+//parseMessage parses a DBC with a single 8 bytes message (id 100) containing the given signal lines
+func parseMessage(t *testing.T, signals ...string) *Message {
+	t.Helper()
+
+	text := "BO_ 100 TEST: 8 NODE\n " + strings.Join(signals, "\n ") + "\n"
+	db, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", text, err)
+	}
+	return db.Messages[100]
+}
+
+func TestSignalByteOrder(t *testing.T) {
+
+	data := []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE, 0xF0}
+	tests := []struct {
+		name   string
+		signal string
+		raw    int64
+	}{
+		{"intel byte", `SG_ S : 8|8@1+ (1,0) [0|0] "" NODE`, 0x34},
+		{"intel word", `SG_ S : 0|16@1+ (1,0) [0|0] "" NODE`, 0x3412},
+		{"intel unaligned", `SG_ S : 4|12@1+ (1,0) [0|0] "" NODE`, 0x341},
+		{"intel 64 bits", `SG_ S : 0|64@1+ (1,0) [0|0] "" NODE`, -0x0F21436587A9CBEE},
+		{"intel nibble", `SG_ S : 60|4@1+ (1,0) [0|0] "" NODE`, 0xF},
+		{"motorola byte", `SG_ S : 15|8@0+ (1,0) [0|0] "" NODE`, 0x34},
+		{"motorola word", `SG_ S : 7|16@0+ (1,0) [0|0] "" NODE`, 0x1234},
+		{"motorola unaligned", `SG_ S : 7|12@0+ (1,0) [0|0] "" NODE`, 0x123},
+		{"motorola inside a byte", `SG_ S : 5|3@0+ (1,0) [0|0] "" NODE`, 0x2},
+		{"motorola across bytes", `SG_ S : 3|8@0+ (1,0) [0|0] "" NODE`, 0x23},
+		{"motorola 64 bits", `SG_ S : 7|64@0+ (1,0) [0|0] "" NODE`, 0x123456789ABCDEF0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signal := parseMessage(t, test.signal).Signal("S")
+			if raw := signal.Raw(data); raw != test.raw {
+				t.Errorf("Raw = 0x%X, want 0x%X", raw, test.raw)
+			}
+		})
+	}
+}
+
+func TestSignalSignExtension(t *testing.T) {
+
+	for width := 1; width <= 64; width++ {
+		for _, order := range []string{"1", "0"} {
+			start := 0
+			if order == "0" {
+				start = 7
+			}
+			line := fmt.Sprintf(`SG_ S : %d|%d@%s- (1,0) [0|0] "" NODE`, start, width, order)
+			signal := parseMessage(t, line).Signal("S")
+
+			//the bits are set from the most significant one of the signal
+			set := func(count int) []byte {
+				data := make([]byte, 8)
+				for i, bit := range signal.bits() {
+					if i < count {
+						data[bit/8] |= 1 << uint(bit%8)
+					}
+				}
+				return data
+			}
+			ones := make([]byte, 8)
+			for i, bit := range signal.bits() {
+				if i > 0 {
+					ones[bit/8] |= 1 << uint(bit%8)
+				}
+			}
+
+			min := int64(math.MinInt64)
+			max := int64(math.MaxInt64)
+			if width < 64 {
+				min = -int64(1) << uint(width-1)
+				max = int64(1)<<uint(width-1) - 1
+			}
+			tests := []struct {
+				name string
+				data []byte
+				raw  int64
+			}{
+				{"zero", make([]byte, 8), 0},
+				{"all ones", set(width), -1},
+				{"sign bit", set(1), min},
+				{"maximum", ones, max},
+			}
+			for _, test := range tests {
+				if raw := signal.Raw(test.data); raw != test.raw {
+					t.Errorf("%d bits @%s %s: Raw = %d, want %d", width, order, test.name, raw, test.raw)
+				}
+			}
+		}
+	}
+}
+
+func TestSignalUnsignedFullWidth(t *testing.T) {
+
+	signal := parseMessage(t, `SG_ S : 0|16@1+ (1,0) [0|0] "" NODE`).Signal("S")
+	if raw := signal.Raw([]byte{0xFF, 0xFF, 0, 0, 0, 0, 0, 0}); raw != 0xFFFF {
+		t.Errorf("Raw = %d, want %d", raw, 0xFFFF)
+	}
+}
+
+func TestSignalFactorOffset(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		signal string
+		data   []byte
+		value  float64
+	}{
+		{"factor", `SG_ S : 0|16@1- (0.01,0) [-180|180] "deg" NODE`, []byte{0x39, 0x30}, 123.45},
+		{"negative factor", `SG_ S : 0|16@1- (0.01,0) [-180|180] "deg" NODE`, []byte{0xC7, 0xCF}, -123.45},
+		{"offset", `SG_ S : 0|8@1+ (1,-40) [-40|215] "C" NODE`, []byte{0x00}, -40},
+		{"factor and offset", `SG_ S : 0|8@1+ (0.5,10) [0|0] "" NODE`, []byte{0x0A}, 15},
+		{"scientific notation", `SG_ S : 0|16@1+ (1e-3,0) [0|65.535] "V" NODE`, []byte{0xA0, 0x41}, 16.8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := make([]byte, 8)
+			copy(data, test.data)
+			value := parseMessage(t, test.signal).Signal("S").Decode(data)
+			if math.Abs(value-test.value) > 1e-9 {
+				t.Errorf("Decode = %v, want %v", value, test.value)
+			}
+		})
+	}
+}
+
+func TestMessageMultiplexing(t *testing.T) {
+
+	message := parseMessage(t,
+		`SG_ MUX M : 0|8@1+ (1,0) [0|255] "" NODE`,
+		`SG_ COMMON : 8|8@1+ (1,0) [0|255] "" NODE`,
+		`SG_ A m0 : 16|16@1+ (1,0) [0|0] "" NODE`,
+		`SG_ B m1 : 16|16@1- (1,0) [0|0] "" NODE`,
+		`SG_ C m1 : 32|8@1+ (1,0) [0|0] "" NODE`,
+	)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		values map[string]float64
+	}{
+		{"first", []byte{0, 7, 0xFF, 0xFF, 9, 0, 0, 0}, map[string]float64{"MUX": 0, "COMMON": 7, "A": 65535}},
+		{"second", []byte{1, 7, 0xFF, 0xFF, 9, 0, 0, 0}, map[string]float64{"MUX": 1, "COMMON": 7, "B": -1, "C": 9}},
+		{"undescribed", []byte{2, 7, 0xFF, 0xFF, 9, 0, 0, 0}, map[string]float64{"MUX": 2, "COMMON": 7}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := message.Decode(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(values) != len(test.values) {
+				t.Errorf("Decode = %v, want %v", values, test.values)
+			}
+			for name, value := range test.values {
+				if got, ok := values[name]; !ok || got != value {
+					t.Errorf("%s = %v (%t), want %v", name, got, ok, value)
+				}
+			}
+		})
+	}
+}
+
+func TestExtendedMultiplexingIgnored(t *testing.T) {
+
+	message := parseMessage(t,
+		`SG_ MUX M : 0|8@1+ (1,0) [0|255] "" NODE`,
+		`SG_ SUB m1M : 8|8@1+ (1,0) [0|255] "" NODE`,
+		`SG_ V m1 : 16|8@1+ (1,0) [0|255] "" NODE`,
+	)
+	if message.Signal("SUB") != nil || message.Signal("V") == nil || len(message.Signals) != 2 {
+		t.Errorf("signals %v, want MUX and V", message.Signals)
+	}
+}
+
+func TestDatabaseMessageID(t *testing.T) {
+
+	text := "BO_ 995 STANDARD: 8 NODE\n SG_ S : 0|8@1+ (1,0) [0|0] \"\" NODE\n\n" +
+		"BO_ 2147484643 EXTENDED: 8 NODE\n SG_ E : 0|8@1+ (1,0) [0|0] \"\" NODE\n"
+	db, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const rtr = 0x40000000
+	const errFlag = 0x20000000
+	tests := []struct {
+		name    string
+		id      uint32
+		message string
+	}{
+		{"standard", 995, "STANDARD"},
+		{"standard RTR", 995 | rtr, "STANDARD"},
+		{"standard error flag", 995 | errFlag, "STANDARD"},
+		{"extended", EXTENDED_ID_FLAG | 995, "EXTENDED"},
+		{"extended RTR", EXTENDED_ID_FLAG | rtr | 995, "EXTENDED"},
+		{"extended error flag", EXTENDED_ID_FLAG | errFlag | 995, "EXTENDED"},
+		{"extended other", EXTENDED_ID_FLAG | 996, ""},
+		{"standard other", 996, ""},
+	}
+
+	data := make([]byte, 8)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, _, err := db.Decode(test.id, data)
+			if test.message == "" {
+				if !errors.Is(err, ErrUnknownMessage) {
+					t.Errorf("Decode(0x%X) = %v, want ErrUnknownMessage", test.id, err)
+				}
+				return
+			}
+			if err != nil || message.Name != test.message {
+				t.Errorf("Decode(0x%X) = %v, %v, want %s", test.id, message, err, test.message)
+			}
+		})
+	}
+}
+
+func TestDecodeShortFrame(t *testing.T) {
+
+	text := "BO_ 100 SHORT: 4 NODE\n SG_ S : 0|32@1+ (1,0) [0|0] \"\" NODE\n"
+	db, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for length := 0; length <= 8; length++ {
+		_, values, err := db.Decode(100, make([]byte, length))
+		if length < 4 && (!errors.Is(err, ErrFrameLength) || values != nil) {
+			t.Errorf("%d bytes: got %v, %v, want ErrFrameLength", length, values, err)
+		}
+		if length >= 4 && err != nil {
+			t.Errorf("%d bytes: %v", length, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"invalid message", "BO_ X TEST: 8 NODE\n"},
+		{"message longer than 8 bytes", "BO_ 100 TEST: 9 NODE\n"},
+		{"signal without message", "SG_ S : 0|8@1+ (1,0) [0|0] \"\" NODE\n"},
+		{"signal after a blank line", "BO_ 100 TEST: 8 NODE\n\n SG_ S : 0|8@1+ (1,0) [0|0] \"\" NODE\n"},
+		{"invalid signal", "BO_ 100 TEST: 8 NODE\n SG_ S : 0|8 (1,0) [0|0] \"\" NODE\n"},
+		{"invalid byte order", "BO_ 100 TEST: 8 NODE\n SG_ S : 0|8@2+ (1,0) [0|0] \"\" NODE\n"},
+		{"invalid factor", "BO_ 100 TEST: 8 NODE\n SG_ S : 0|8@1+ (x,0) [0|0] \"\" NODE\n"},
+		{"invalid minimum", "BO_ 100 TEST: 8 NODE\n SG_ S : 0|8@1+ (1,0) [a|0] \"\" NODE\n"},
+		{"zero length", "BO_ 100 TEST: 8 NODE\n SG_ S : 0|0@1+ (1,0) [0|0] \"\" NODE\n"},
+		{"intel out of the message", "BO_ 100 TEST: 2 NODE\n SG_ S : 8|16@1+ (1,0) [0|0] \"\" NODE\n"},
+		{"motorola out of the message", "BO_ 100 TEST: 1 NODE\n SG_ S : 7|16@0+ (1,0) [0|0] \"\" NODE\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if db, err := Parse(strings.NewReader(test.text)); err == nil {
+				t.Errorf("Parse(%q) = %v, want an error", test.text, db.Messages)
+			}
+		})
+	}
+}
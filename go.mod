@@ -3,13 +3,13 @@ module github.com/arslab/robot_controller
 go 1.15
 
 require (
-	github.com/brutella/can v0.0.2 // indirect
+	github.com/brutella/can v0.0.2
 	github.com/d2r2/go-i2c v0.0.0-20191123181816-73a8a799d6bc // indirect
 	github.com/d2r2/go-logger v0.0.0-20181221090742-9998a510495e // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fatih/color v1.13.0
 	github.com/gin-contrib/cors v1.3.1
-	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/gin-contrib/sse v0.1.0
 	github.com/gin-gonic/gin v1.7.7
 	github.com/go-playground/assert/v2 v2.0.1 // indirect
 	github.com/go-playground/locales v0.14.0 // indirect
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+//Sources of the battery charge
+const (
+	BATTERY_SOURCE_TELEMETRY = "telemetry"
+	BATTERY_SOURCE_TIMER     = "timer"
+)
+
+//BatteryPoint is a point of the discharge curve: the charge percentage at the given voltage
+type BatteryPoint struct {
+	Voltage float64 `json:"voltage"`
+	Percent float64 `json:"percent"`
+}
+
+//BatteryConfig rappresents the battery pack: its discharge curve, the low charge threshold (percent)
+//and the duration (seconds) of a full pack used by the timer estimate
+type BatteryConfig struct {
+	Curve         []BatteryPoint `json:"curve"`
+	LowPercent    float64        `json:"low_percent"`
+	TimerDuration int16          `json:"timer_duration"`
+}
+
+//Battery rappresents the battery charge. Percent is computed from the voltage telemetry
+//or, when no battery frame is received, estimated by the timer.
+type Battery struct {
+	Source         string     `json:"source"`
+	Percent        float64    `json:"percent"`
+	Low            bool       `json:"low"`
+	Voltage        float64    `json:"voltage"`
+	Current        float64    `json:"current"`
+	LastUpdate     *time.Time `json:"last_update,omitempty"`
+	TimerRemaining int16      `json:"timer_remaining"`
+	TimerPercent   float64    `json:"timer_percent"`
+}
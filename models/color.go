@@ -0,0 +1,8 @@
+package models
+
+//Team colors. The field coordinates are the ones seen from the COLOR_PRIMARY side,
+//the team coordinates of COLOR_SECONDARY are mirrored (X and angle).
+const (
+	COLOR_PRIMARY   = 0
+	COLOR_SECONDARY = 1
+)
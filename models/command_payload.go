@@ -0,0 +1,43 @@
+package models
+
+//PositionPayload rappresents the position sent to set_position, set_start_position and move_point (the angle is ignored)
+type PositionPayload struct {
+	X     int16 `json:"x"`
+	Y     int16 `json:"y"`
+	Angle int16 `json:"angle"`
+}
+
+//ColorPayload rappresents the team color sent to set_color and align
+type ColorPayload struct {
+	Color uint8 `json:"color"`
+}
+
+//SpeedPayload rappresents the speed sent to set_speed
+type SpeedPayload struct {
+	Speed int16 `json:"speed"`
+}
+
+//DistancePayload rappresents the distance (mm) sent to move_distance
+type DistancePayload struct {
+	Distance int16 `json:"distance"`
+}
+
+//RotationPayload rappresents the angle (degrees) sent to rotate_relative and rotate_absolute
+type RotationPayload struct {
+	Angle int16 `json:"angle"`
+}
+
+//EnablePayload rappresents the flag sent to starter and set_recorder
+type EnablePayload struct {
+	Enable bool `json:"enable"`
+}
+
+//ReplayStepPayload rappresents the number of frames sent to replay_step
+type ReplayStepPayload struct {
+	Frames int `json:"frames"`
+}
+
+//PathPayload rappresents the waypoints sent to start_path
+type PathPayload struct {
+	Waypoints []Waypoint `json:"waypoints"`
+}
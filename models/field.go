@@ -0,0 +1,27 @@
+package models
+
+//Point rappresents a 2D point of the field (mm)
+type Point struct {
+	X int16 `json:"x"`
+	Y int16 `json:"y"`
+}
+
+//FieldObstacle rappresents a static element of the field the robot can not cross
+type FieldObstacle struct {
+	Name    string  `json:"name"`
+	Polygon []Point `json:"polygon"`
+}
+
+//Field rappresents the playing field layout: the table boundary, the static obstacles and the forbidden zones.
+//RobotRadius and OpponentRadius are used to keep the robot away from the obstacles.
+//The motion targets outside the boundary or inside a forbidden zone are refused.
+type Field struct {
+	MinX           int16           `json:"min_x"`
+	MinY           int16           `json:"min_y"`
+	MaxX           int16           `json:"max_x"`
+	MaxY           int16           `json:"max_y"`
+	RobotRadius    int16           `json:"robot_radius"`
+	OpponentRadius int16           `json:"opponent_radius"`
+	Obstacles      []FieldObstacle `json:"obstacles"`
+	Forbidden      []FieldObstacle `json:"forbidden"`
+}
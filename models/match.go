@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+//States of the match clock
+const (
+	MATCH_WAITING  = "waiting"
+	MATCH_RUNNING  = "running"
+	MATCH_FINISHED = "finished"
+)
+
+//MatchStatus rappresents the match clock. Duration, Elapsed and Remaining are in seconds.
+type MatchStatus struct {
+	State      string     `json:"state"`
+	Duration   float64    `json:"duration"`
+	Elapsed    float64    `json:"elapsed"`
+	Remaining  float64    `json:"remaining"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -9,11 +9,15 @@ const (
 	MC_SET_SPEED       = 0x8C
 )
 
-//Position rappresent a 2D point with angle
+//MC_FW_TO_POINT is the forward to point command, its code is not defined by the firmware sources
+//of the other commands so it can be changed at startup (-cmd-fw-to-point)
+var MC_FW_TO_POINT uint8 = 0x86
+
+//MotionCommand rappresents the payload of a motion command frame
 type MotionCommand struct {
 	CMD     uint8
 	PARAM_1 int16
 	PARAM_2 int16
 	PARAM_3 int16
-	flags   int8
+	FLAGS   int8
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+//States of a motion goal
+const (
+	GOAL_PENDING   = "pending"
+	GOAL_RUNNING   = "running"
+	GOAL_REACHED   = "reached"
+	GOAL_BLOCKED   = "blocked"
+	GOAL_TIMEOUT   = "timeout"
+	GOAL_PREEMPTED = "preempted"
+	GOAL_FAILED    = "failed"
+)
+
+//MotionGoal rappresents the target of a motion command and its outcome
+type MotionGoal struct {
+	ID         uint64     `json:"id"`
+	Command    string     `json:"command"`
+	Target     Position   `json:"target"`
+	State      string     `json:"state"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+//Active returns true if the goal is not finished
+func (goal MotionGoal) Active() bool {
+	return goal.State == GOAL_PENDING || goal.State == GOAL_RUNNING
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+//Obstacle rappresents an entry of the obstacle map, seen by the robot sensors in an angular sector
+type Obstacle struct {
+	Number     uint8     `json:"number"`
+	Valid      bool      `json:"valid"`
+	AngleStart int16     `json:"angle_start"`
+	AngleEnd   int16     `json:"angle_end"`
+	Distance   int16     `json:"distance"`
+	X          int16     `json:"x"`
+	Y          int16     `json:"y"`
+	Radius     int16     `json:"radius"`
+	LastSeen   time.Time `json:"last_seen"`
+	Expires    time.Time `json:"expires"`
+}
@@ -0,0 +1,10 @@
+package models
+
+//ObstacleFrame rappresents the payload of an obstacle map frame
+type ObstacleFrame struct {
+	Number     uint8
+	Valid      uint8
+	AngleStart int16
+	AngleEnd   int16
+	Distance   int16
+}
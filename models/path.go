@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+//States of a path
+const (
+	PATH_IDLE      = "idle"
+	PATH_RUNNING   = "running"
+	PATH_PAUSED    = "paused"
+	PATH_COMPLETED = "completed"
+	PATH_CANCELLED = "cancelled"
+	PATH_FAILED    = "failed"
+)
+
+//Waypoint rappresents a point of a path.
+//Angle is the optional heading at the waypoint, Speed (if not 0) is set before moving to the waypoint
+//and Tolerance is the distance (mm) within which the waypoint is considered passed.
+type Waypoint struct {
+	X         int16  `json:"x"`
+	Y         int16  `json:"y"`
+	Angle     *int16 `json:"angle,omitempty"`
+	Speed     int16  `json:"speed,omitempty"`
+	Tolerance int16  `json:"tolerance,omitempty"`
+}
+
+//PathStatus rappresents the progress of a path
+type PathStatus struct {
+	State      string      `json:"state"`
+	Waypoints  []Waypoint  `json:"waypoints"`
+	Current    int         `json:"current"`
+	Completed  int         `json:"completed"`
+	Progress   float64     `json:"progress"`
+	Error      string      `json:"error,omitempty"`
+	Goal       *MotionGoal `json:"goal,omitempty"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+//Active returns true if the path is running or paused
+func (status PathStatus) Active() bool {
+	return status.State == PATH_RUNNING || status.State == PATH_PAUSED
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+//States of a planned motion
+const (
+	PLAN_IDLE      = "idle"
+	PLAN_RUNNING   = "running"
+	PLAN_REACHED   = "reached"
+	PLAN_CANCELLED = "cancelled"
+	PLAN_FAILED    = "failed"
+)
+
+//PlanStatus rappresents the progress of a planned motion to a goal.
+//Waypoints is the path currently executed and Replans counts the paths computed again because of new obstacles.
+type PlanStatus struct {
+	State      string     `json:"state"`
+	Goal       Waypoint   `json:"goal"`
+	Waypoints  []Waypoint `json:"waypoints"`
+	Replans    int        `json:"replans"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+//Active returns true if the robot is moving to the goal
+func (status PlanStatus) Active() bool {
+	return status.State == PLAN_RUNNING
+}
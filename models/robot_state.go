@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+//RobotState rappresents a consistent snapshot of the robot state.
+//Sequence is incremented at every change and Timestamp is the time of the last change.
+type RobotState struct {
+	Sequence            uint64      `json:"sequence"`
+	Timestamp           time.Time   `json:"timestamp"`
+	Position            Position    `json:"position"`
+	StartPositionSetted bool        `json:"start_position_setted"`
+	StartPosition       Position    `json:"start_position"`
+	OtherPosition       Position    `json:"other_position"`
+	OtherPositionUpdate time.Time   `json:"other_position_update"`
+	Speed               int16       `json:"speed"`
+	Status              RobotStatus `json:"status"`
+	Goal                MotionGoal  `json:"goal"`
+	Color               uint8       `json:"color"`
+	StarterEnabled      bool        `json:"starter_enabled"`
+	TimerBattery        int16       `json:"timer_battery"`
+	BatteryVoltage      float64     `json:"battery_voltage"`
+	BatteryCurrent      float64     `json:"battery_current"`
+	BatteryUpdate       time.Time   `json:"battery_update"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+//Status word bits of the ID_ROBOT_STATUS frame in the built-in description (DEFAULT_DBC), sent by the virtual robot.
+//The robot reads the flags from the signals of the DBC, so a different layout only needs a DBC file
+const (
+	STATUS_MOVING          = 0x0001
+	STATUS_TARGET_REACHED  = 0x0002
+	STATUS_BLOCKED         = 0x0004
+	STATUS_ERROR           = 0x0008
+	STATUS_STARTER_ENABLED = 0x0010
+	STATUS_STARTER_PULLED  = 0x0020
+	STATUS_ERROR_CODE_MASK = 0xFF00
+)
+
+//States of the robot derived from the status word
+const (
+	STATE_UNKNOWN        = "unknown"
+	STATE_IDLE           = "idle"
+	STATE_MOVING         = "moving"
+	STATE_TARGET_REACHED = "target_reached"
+	STATE_BLOCKED        = "blocked"
+	STATE_ERROR          = "error"
+)
+
+//RobotStatus rappresents the decoded status word of the robot
+type RobotStatus struct {
+	Word           uint16    `json:"word"`
+	State          string    `json:"state"`
+	Moving         bool      `json:"moving"`
+	TargetReached  bool      `json:"target_reached"`
+	Blocked        bool      `json:"blocked"`
+	Error          bool      `json:"error"`
+	ErrorCode      uint8     `json:"error_code"`
+	StarterEnabled bool      `json:"starter_enabled"`
+	StarterPulled  bool      `json:"starter_pulled"`
+	Since          time.Time `json:"since"`
+}
+
+//StatusState returns the state of the robot derived from the status flags and the error code
+func StatusState(status RobotStatus) string {
+
+	//the most severe condition determines the state
+	switch {
+	case status.Error || status.ErrorCode != 0:
+		return STATE_ERROR
+	case status.Blocked:
+		return STATE_BLOCKED
+	case status.Moving:
+		return STATE_MOVING
+	case status.TargetReached:
+		return STATE_TARGET_REACHED
+	default:
+		return STATE_IDLE
+	}
+}
@@ -0,0 +1,14 @@
+package models
+
+//TOPIC_MODE_CHANGE sends the topic when it changes (at most once per interval)
+//TOPIC_MODE_INTERVAL sends the topic at every interval, even if it didn't change
+const (
+	TOPIC_MODE_CHANGE   = "change"
+	TOPIC_MODE_INTERVAL = "interval"
+)
+
+//TopicOptions rappresents the delivery options of a subscribed topic
+type TopicOptions struct {
+	Mode     string `json:"mode"`
+	Interval int    `json:"interval"` //milliseconds
+}
@@ -1,7 +1,36 @@
 package models
 
-//WebSocketMessage rappresents a base I2C payload
+import "encoding/json"
+
+//WebSocketMessage rappresents a topic or an event sent to the websocket clients,
+//the payload is already JSON encoded so it's encoded once for every client
 type WebSocketMessage struct {
+	Command string          `json:"command"`
+	Payload json.RawMessage `json:"data"`
+}
+
+//WebSocketRequest rappresents a command sent by a websocket client,
+//the id is copied in the reply, the query carries the query and path parameters of the REST operation
+//and the data is decoded into the payload of the command (e.g. PositionPayload)
+type WebSocketRequest struct {
+	Command string            `json:"command"`
+	ID      string            `json:"id,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Data    json.RawMessage   `json:"data,omitempty"`
+}
+
+//WebSocketResponse rappresents the reply to a websocket request, its command is "ack" or "error"
+type WebSocketResponse struct {
+	Command string         `json:"command"`
+	ID      string         `json:"id,omitempty"`
+	Data    WebSocketReply `json:"data"`
+}
+
+//WebSocketReply rappresents the data of the ack and error replies,
+//the result is the body of the REST reply
+type WebSocketReply struct {
+	Status  int         `json:"status"`
 	Command string      `json:"command"`
-	Payload interface{} `json:"data"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
 }
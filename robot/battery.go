@@ -0,0 +1,176 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+const (
+	BATTERY_TIMEOUT        = 5 * time.Second //the timer estimate is used if no battery frame is received within this time
+	BATTERY_LOW_HYSTERESIS = 5               //percent above the low threshold needed to clear the low battery warning
+)
+
+//DEFAULT_BATTERY is the discharge curve of a 4S LiPo pack, used when no battery file is loaded
+const DEFAULT_BATTERY = `{
+	"curve": [
+		{"voltage": 13.2, "percent": 0},
+		{"voltage": 14.0, "percent": 5},
+		{"voltage": 14.4, "percent": 10},
+		{"voltage": 14.8, "percent": 22},
+		{"voltage": 15.2, "percent": 40},
+		{"voltage": 15.6, "percent": 60},
+		{"voltage": 16.0, "percent": 76},
+		{"voltage": 16.4, "percent": 90},
+		{"voltage": 16.8, "percent": 100}
+	],
+	"low_percent": 20,
+	"timer_duration": 1500
+}`
+
+//ParseBatteryConfig returns the battery configuration described by the given JSON
+func ParseBatteryConfig(data []byte) (models.BatteryConfig, error) {
+
+	config := models.BatteryConfig{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+
+	if len(config.Curve) < 2 {
+		return config, errors.New("the discharge curve needs at least 2 points")
+	}
+	sort.Slice(config.Curve, func(i, j int) bool { return config.Curve[i].Voltage < config.Curve[j].Voltage })
+	for i, point := range config.Curve {
+		if point.Percent < 0 || point.Percent > 100 {
+			return config, errors.New("the discharge curve percentages must be between 0 and 100")
+		}
+		if i > 0 && point.Voltage == config.Curve[i-1].Voltage {
+			return config, errors.New("the discharge curve has duplicated voltages")
+		}
+	}
+	if config.TimerDuration <= 0 {
+		return config, errors.New("invalid timer duration")
+	}
+	return config, nil
+}
+
+//LoadBatteryConfig replaces the battery configuration with the one of the given JSON file
+func (robot *Robot) LoadBatteryConfig(path string) error {
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		var config models.BatteryConfig
+		config, err = ParseBatteryConfig(data)
+		if err == nil {
+			robot.mutex.Lock()
+			robot.batteryConfig = config
+			robot.state.TimerBattery = config.TimerDuration
+			robot.mutex.Unlock()
+
+			printInfo("Battery configuration loaded from " + path)
+			return nil
+		}
+	}
+
+	printError("Battery " + path + ": " + err.Error())
+	return err
+}
+
+//SetCallbackBatteryLow set the function called when the battery charge goes below the low threshold
+func (robot *Robot) SetCallbackBatteryLow(cb func(battery models.Battery)) {
+	robot.mutex.Lock()
+	defer robot.mutex.Unlock()
+	robot.callbackBatteryLow = cb
+}
+
+//GetBattery returns the battery charge, from the telemetry or from the timer estimate
+func (robot *Robot) GetBattery() models.Battery {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
+	return robot.battery()
+}
+
+//battery returns the battery charge, the robot mutex must be locked
+func (robot *Robot) battery() models.Battery {
+
+	config := robot.batteryConfig
+	battery := models.Battery{
+		Source:         models.BATTERY_SOURCE_TIMER,
+		TimerRemaining: robot.state.TimerBattery,
+		TimerPercent:   100 * float64(robot.state.TimerBattery) / float64(config.TimerDuration),
+		Low:            robot.batteryLow,
+	}
+	battery.Percent = battery.TimerPercent
+
+	if !robot.state.BatteryUpdate.IsZero() {
+		update := robot.state.BatteryUpdate
+		battery.LastUpdate = &update
+		battery.Voltage = robot.state.BatteryVoltage
+		battery.Current = robot.state.BatteryCurrent
+		if time.Since(update) < BATTERY_TIMEOUT {
+			battery.Source = models.BATTERY_SOURCE_TELEMETRY
+			battery.Percent = batteryPercent(config.Curve, battery.Voltage)
+		}
+	}
+	return battery
+}
+
+//ResetBatteryTimer restarts the timer estimate, to be called when the pack is swapped
+func (robot *Robot) ResetBatteryTimer() models.Battery {
+	robot.mutex.Lock()
+	robot.state.TimerBattery = robot.batteryConfig.TimerDuration
+	robot.state.Sequence++
+	robot.state.Timestamp = time.Now()
+	robot.mutex.Unlock()
+
+	printInfo("Battery timer reset")
+	robot.checkBattery()
+	return robot.GetBattery()
+}
+
+//checkBattery updates the low battery warning, the callback is called when the charge goes below the threshold
+func (robot *Robot) checkBattery() {
+
+	robot.mutex.Lock()
+	battery := robot.battery()
+	low := robot.batteryLow
+	if !low && battery.Percent < robot.batteryConfig.LowPercent {
+		low = true
+	} else if low && battery.Percent >= robot.batteryConfig.LowPercent+BATTERY_LOW_HYSTERESIS {
+		low = false
+	}
+	changed := low != robot.batteryLow
+	robot.batteryLow = low
+	battery.Low = low
+	callback := robot.callbackBatteryLow
+	robot.mutex.Unlock()
+
+	if changed && low {
+		printError(fmt.Sprintf("Low battery: %.0f%% (%s)", battery.Percent, battery.Source))
+		if callback != nil {
+			callback(battery)
+		}
+	}
+}
+
+//batteryPercent returns the charge percentage of the voltage, interpolating the discharge curve
+func batteryPercent(curve []models.BatteryPoint, voltage float64) float64 {
+	if len(curve) == 0 {
+		return 0
+	}
+	if voltage <= curve[0].Voltage {
+		return curve[0].Percent
+	}
+	for i := 1; i < len(curve); i++ {
+		if voltage <= curve[i].Voltage {
+			t := (voltage - curve[i-1].Voltage) / (curve[i].Voltage - curve[i-1].Voltage)
+			return curve[i-1].Percent + t*(curve[i].Percent-curve[i-1].Percent)
+		}
+	}
+	return curve[len(curve)-1].Percent
+}
@@ -4,11 +4,12 @@ import (
 	//"bytes"
 	//"encoding/binary"
 
-	"bytes"
-	"encoding/binary"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
 
 	//"time"
 
@@ -17,10 +18,10 @@ import (
 	"github.com/fatih/color"
 )
 
-//Connection is the interface between the logical Robot and the i2C Bus
+//Connection is the interface between the logical Robot and the Transport
 type Connection struct {
-	Interface string
-	Bus       *can.Bus
+	Transport Transport
+	Recorder  *Recorder
 	OnReceive func(data can.Frame)
 }
 
@@ -63,89 +64,93 @@ type Connection struct {
 // 	//log.Printf("%-3s %-4x %-3s % -24X '%s'\n", "can0", frm.ID, length, data, printableString(data[:]))
 // }
 
-//NewConnection return a new I2C Connection specifying the GPIO Pin and the Address of the device
-func NewConnection(networkInterface string) *Connection {
-
-	bus, err := can.NewBusForInterfaceWithName(networkInterface)
-	if err != nil {
-		log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
-		os.Exit(1)
-	}
+//NewConnection return a new Connection over the given Transport
+func NewConnection(transport Transport) *Connection {
 
 	connection := Connection{
-		Interface: networkInterface,
-		Bus:       bus,
+		Transport: transport,
+		Recorder:  NewRecorder(RECORDER_DEFAULT_DIRECTORY, RECORDER_DEFAULT_INTERFACE),
 	}
 
 	return &connection
 }
 
+//OnReceiveCallback set the function called for every frame received from the Transport
 func (conn *Connection) OnReceiveCallback(cb func(data can.Frame)) {
 	conn.OnReceive = cb
-	conn.Bus.SubscribeFunc(conn.OnReceive)
+	conn.Transport.Subscribe(func(frm can.Frame) {
+		conn.record(frm)
+		conn.OnReceive(frm)
+	})
 }
 
-//Init initialise the CAN connection
+//Init initialise the connection
 func (conn *Connection) Init() error {
 
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, os.Kill)
+	if conn.Transport == nil {
+		return errors.New("no transport configured")
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 
 		select {
 		case <-c:
-			log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiYellow), "Connection Closed on Interface:"+conn.Interface)
-			conn.Bus.Disconnect()
+			log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiYellow), "Connection Closed on Transport:"+conn.Transport.Name())
+			conn.Transport.Close()
+			conn.Recorder.Stop()
 			os.Exit(1)
 		}
 	}()
 
-	log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgYellow), "Connection Initialised on Interface:"+conn.Interface)
+	log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgYellow), "Connection Initialised on Transport:"+conn.Transport.Name())
 	return nil
 }
 
 func (conn *Connection) Disconnect() {
-	conn.Bus.Disconnect()
+	conn.Transport.Close()
 }
 
 func (conn *Connection) Connect() {
 	go func() {
-		conn.Bus.ConnectAndPublish()
+		if err := conn.Transport.Connect(); err != nil {
+			log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
+		}
 	}()
 }
 
-//SendData allows to send data through the bus
-func (conn *Connection) SendData(payload interface{}, id uint32) error {
+func (conn *Connection) record(frm can.Frame) {
+	conn.Recorder.Record(frm)
+}
 
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, payload)
-	if err != nil {
+//Stats returns the frame counters of the Transport
+func (conn *Connection) Stats() TransportStats {
+	return conn.Transport.Stats()
+}
+
+//SendFrame allows to send the frame data through the Transport
+func (conn *Connection) SendFrame(id uint32, data []byte) error {
+
+	if len(data) > can.MaxFrameDataLength {
+		err := fmt.Errorf("frame 0x%X: %d bytes exceed the maximum length", id, len(data))
 		log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
 		return err
 	}
-	err = nil
-
-	var pl [8]uint8 // = buf.Bytes()
-	for i, v := range buf.Bytes() {
-		pl[i] = v
-	}
-
-	//log.Println(pl)
 
 	frm := can.Frame{
-		Length: 8,
+		Length: uint8(len(data)),
 		ID:     id,
-		Data:   pl,
 	}
-	err = conn.Bus.Publish(frm)
-	if err != nil {
-		log.Println("Errore nella publish")
-		log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), err)
+	copy(frm.Data[:], data)
 
+	err := conn.Transport.Send(frm)
+	if err != nil {
+		log.Printf("[%s] %s", utilities.CreateColorString("CONNECTION", color.FgHiRed), fmt.Sprintf("Send of frame 0x%X failed: %s", id, err))
 		return err
 	}
+	conn.record(frm)
 
 	return nil
 }
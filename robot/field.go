@@ -0,0 +1,133 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+//DEFAULT_FIELD is the layout of an empty table, used when no field file is loaded
+const DEFAULT_FIELD = `{
+	"min_x": 0,
+	"min_y": 0,
+	"max_x": 3000,
+	"max_y": 2000,
+	"robot_radius": 150,
+	"opponent_radius": 200,
+	"obstacles": [],
+	"forbidden": []
+}`
+
+//ErrGeofence is returned when a motion target is outside the field or inside a forbidden zone
+var ErrGeofence = errors.New("target outside the allowed area")
+
+//ParseField returns the field layout described by the given JSON
+func ParseField(data []byte) (models.Field, error) {
+
+	field := models.Field{}
+	if err := json.Unmarshal(data, &field); err != nil {
+		return field, err
+	}
+
+	if field.MaxX <= field.MinX || field.MaxY <= field.MinY {
+		return field, errors.New("invalid field boundary")
+	}
+	if field.RobotRadius < 0 || field.OpponentRadius < 0 {
+		return field, errors.New("invalid robot radius")
+	}
+	for _, obstacle := range field.Obstacles {
+		if len(obstacle.Polygon) < 3 {
+			return field, errors.New("the field obstacle " + obstacle.Name + " has less than 3 points")
+		}
+	}
+	for _, zone := range field.Forbidden {
+		if len(zone.Polygon) < 3 {
+			return field, errors.New("the forbidden zone " + zone.Name + " has less than 3 points")
+		}
+	}
+	return field, nil
+}
+
+//LoadField replaces the field layout with the one of the given JSON file
+func (robot *Robot) LoadField(path string) error {
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		var field models.Field
+		field, err = ParseField(data)
+		if err == nil {
+			robot.mutex.Lock()
+			robot.field = field
+			robot.mutex.Unlock()
+
+			printInfo("Field layout loaded from " + path)
+			return nil
+		}
+	}
+
+	printError("Field " + path + ": " + err.Error())
+	return err
+}
+
+//GetField returns the field layout
+func (robot *Robot) GetField() models.Field {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
+	return robot.field
+}
+
+//CheckGeofence returns an error wrapping ErrGeofence if the point is outside the field boundary or inside a forbidden zone
+func (robot *Robot) CheckGeofence(x int16, y int16) error {
+
+	field := robot.GetField()
+	if x < field.MinX || x > field.MaxX || y < field.MinY || y > field.MaxY {
+		return fmt.Errorf("%w: X: %d, Y: %d is outside the table", ErrGeofence, x, y)
+	}
+	for _, zone := range field.Forbidden {
+		if pointInPolygon(float64(x), float64(y), zone.Polygon) {
+			return fmt.Errorf("%w: X: %d, Y: %d is inside the forbidden zone %s", ErrGeofence, x, y, zone.Name)
+		}
+	}
+	return nil
+}
+
+//pointInPolygon returns true if the point is inside the polygon (even-odd rule)
+func pointInPolygon(x float64, y float64, polygon []models.Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		xi, yi := float64(polygon[i].X), float64(polygon[i].Y)
+		xj, yj := float64(polygon[j].X), float64(polygon[j].Y)
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+//signedPolygonDistance returns the distance between the point and the polygon edges,
+//negative if the point is inside the polygon
+func signedPolygonDistance(x float64, y float64, polygon []models.Point) float64 {
+	distance := math.Inf(1)
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		distance = math.Min(distance, segmentDistance(x, y, polygon[j], polygon[i]))
+	}
+	if pointInPolygon(x, y, polygon) {
+		return -distance
+	}
+	return distance
+}
+
+//segmentDistance returns the distance between the point and the segment a-b
+func segmentDistance(x float64, y float64, a models.Point, b models.Point) float64 {
+	ax, ay := float64(a.X), float64(a.Y)
+	dx, dy := float64(b.X)-ax, float64(b.Y)-ay
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, ((x-ax)*dx+(y-ay)*dy)/length))
+	}
+	return math.Hypot(x-(ax+t*dx), y-(ay+t*dy))
+}
@@ -0,0 +1,142 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+//Coordinate frames of the positions exchanged with the clients
+const (
+	FRAME_FIELD = "field" //raw field coordinates, as sent by the robot
+	FRAME_TEAM  = "team"  //coordinates of our side: mirrored when playing as COLOR_SECONDARY
+	FRAME_START = "start" //coordinates relative to the start pose: origin in the start position, X axis along the start heading
+)
+
+var (
+	ErrUnknownFrame    = errors.New("unknown coordinate frame")
+	ErrNoStartPosition = errors.New("start position unknown: no position received from the robot")
+)
+
+//SetColor changes the team color without aligning the robot
+func (robot *Robot) SetColor(color uint8) {
+	robot.updateState(func(state *models.RobotState) {
+		state.Color = color
+	})
+}
+
+//GetColor returns the team color
+func (robot *Robot) GetColor() uint8 {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
+	return robot.state.Color
+}
+
+//Mirrored returns true if the team coordinates are mirrored
+func (robot *Robot) Mirrored() bool {
+	return robot.GetColor() == models.COLOR_SECONDARY
+}
+
+//ToFrame converts a field position to the given frame ("" is the field frame)
+func (robot *Robot) ToFrame(frame string, position models.Position) (models.Position, error) {
+	switch frame {
+	case "", FRAME_FIELD:
+		return position, nil
+	case FRAME_TEAM:
+		return robot.mirror(position), nil
+	case FRAME_START:
+		start, ok := robot.GetStartPosition()
+		if !ok {
+			return position, ErrNoStartPosition
+		}
+		return toStartFrame(start, position), nil
+	}
+	return position, ErrUnknownFrame
+}
+
+//FromFrame converts a position of the given frame to the field frame
+func (robot *Robot) FromFrame(frame string, position models.Position) (models.Position, error) {
+	switch frame {
+	case "", FRAME_FIELD:
+		return position, nil
+	case FRAME_TEAM:
+		//the mirror is its own inverse
+		return robot.mirror(position), nil
+	case FRAME_START:
+		start, ok := robot.GetStartPosition()
+		if !ok {
+			return position, ErrNoStartPosition
+		}
+		return fromStartFrame(start, position), nil
+	}
+	return position, ErrUnknownFrame
+}
+
+//FromFrameRotation converts a relative rotation of the given frame to the field frame
+func (robot *Robot) FromFrameRotation(frame string, degree int16) (int16, error) {
+	switch frame {
+	case "", FRAME_FIELD, FRAME_START:
+		return degree, nil
+	case FRAME_TEAM:
+		if robot.Mirrored() {
+			return -degree, nil
+		}
+		return degree, nil
+	}
+	return degree, ErrUnknownFrame
+}
+
+//GetStartPosition returns the start pose, false if no position was received yet
+func (robot *Robot) GetStartPosition() (models.Position, bool) {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
+	return robot.state.StartPosition, robot.state.StartPositionSetted
+}
+
+//SetStartPosition changes the start pose of the start-relative frame.
+//The start pose is the first position received from the robot and the position at the start of the match.
+func (robot *Robot) SetStartPosition(position models.Position) {
+	robot.updateState(func(state *models.RobotState) {
+		state.StartPositionSetted = true
+		state.StartPosition = position
+	})
+	printInfo(fmt.Sprintf("Start Position: X: %d, Y: %d, Angle: %d", position.X, position.Y, position.Angle))
+}
+
+//toStartFrame returns the position relative to the start pose
+func toStartFrame(start models.Position, position models.Position) models.Position {
+	radians := float64(start.Angle) * math.Pi / 180
+	dx := float64(position.X) - float64(start.X)
+	dy := float64(position.Y) - float64(start.Y)
+	return models.Position{
+		X:     clampInt16(dx*math.Cos(radians) + dy*math.Sin(radians)),
+		Y:     clampInt16(-dx*math.Sin(radians) + dy*math.Cos(radians)),
+		Angle: int16(angleDifference(position.Angle, start.Angle)),
+	}
+}
+
+//fromStartFrame returns the field position of a position relative to the start pose
+func fromStartFrame(start models.Position, position models.Position) models.Position {
+	radians := float64(start.Angle) * math.Pi / 180
+	x, y := float64(position.X), float64(position.Y)
+	return models.Position{
+		X:     clampInt16(float64(start.X) + x*math.Cos(radians) - y*math.Sin(radians)),
+		Y:     clampInt16(float64(start.Y) + x*math.Sin(radians) + y*math.Cos(radians)),
+		Angle: int16(normalizeAngle(float64(position.Angle) + float64(start.Angle))),
+	}
+}
+
+//mirror returns the position mirrored about the field center line (X = (MinX + MaxX) / 2) if the team color requires it
+func (robot *Robot) mirror(position models.Position) models.Position {
+	if !robot.Mirrored() {
+		return position
+	}
+	field := robot.GetField()
+	return models.Position{
+		X:     clampInt16(float64(field.MinX) + float64(field.MaxX) - float64(position.X)),
+		Y:     position.Y,
+		Angle: int16(normalizeAngle(180 - float64(position.Angle))),
+	}
+}
@@ -0,0 +1,179 @@
+package robot
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/utilities"
+	"github.com/fatih/color"
+)
+
+const MATCH_DURATION = 100 * time.Second
+
+var (
+	ErrMatchFinished = errors.New("the match is finished: motion commands are disabled")
+	ErrMatchRunning  = errors.New("the match is already running")
+)
+
+//MatchClock measures the match time from the starter pull.
+//At the end of the match the motors are stopped and the motion commands are refused until the clock is reset.
+type MatchClock struct {
+	robot      *Robot
+	mutex      sync.RWMutex
+	duration   time.Duration
+	state      string
+	startedAt  time.Time
+	finishedAt time.Time
+	timer      *time.Timer
+	generation uint64 //incremented by every start and reset, a timer of an older match is ignored
+	callback   func(status models.MatchStatus)
+}
+
+//NewMatchClock returns a waiting MatchClock for the given robot
+func NewMatchClock(robot *Robot, duration time.Duration) *MatchClock {
+	return &MatchClock{
+		robot:    robot,
+		duration: duration,
+		state:    models.MATCH_WAITING,
+	}
+}
+
+//SetCallbackUpdate set the function called when the match starts, ends or is reset
+func (match *MatchClock) SetCallbackUpdate(cb func(status models.MatchStatus)) {
+	match.mutex.Lock()
+	defer match.mutex.Unlock()
+	match.callback = cb
+}
+
+//SetDuration changes the match duration, it is applied to the next match
+func (match *MatchClock) SetDuration(duration time.Duration) {
+	match.mutex.Lock()
+	defer match.mutex.Unlock()
+	match.duration = duration
+}
+
+//Status returns the state of the match clock
+func (match *MatchClock) Status() models.MatchStatus {
+	match.mutex.RLock()
+	defer match.mutex.RUnlock()
+
+	status := models.MatchStatus{
+		State:    match.state,
+		Duration: match.duration.Seconds(),
+	}
+	if match.state != models.MATCH_WAITING {
+		startedAt := match.startedAt
+		status.StartedAt = &startedAt
+	}
+	if match.state == models.MATCH_FINISHED {
+		finishedAt := match.finishedAt
+		status.FinishedAt = &finishedAt
+	}
+	status.Elapsed = match.elapsed().Seconds()
+	status.Remaining = math.Max(0, (match.duration - match.elapsed()).Seconds())
+	return status
+}
+
+//Elapsed returns the time since the starter pull (0 before the match)
+func (match *MatchClock) Elapsed() time.Duration {
+	match.mutex.RLock()
+	defer match.mutex.RUnlock()
+	return match.elapsed()
+}
+
+//elapsed returns the match time, the mutex must be locked
+func (match *MatchClock) elapsed() time.Duration {
+	switch match.state {
+	case models.MATCH_RUNNING:
+		return time.Since(match.startedAt)
+	case models.MATCH_FINISHED:
+		return match.finishedAt.Sub(match.startedAt)
+	}
+	return 0
+}
+
+//Finished returns true if the match is over
+func (match *MatchClock) Finished() bool {
+	match.mutex.RLock()
+	defer match.mutex.RUnlock()
+	return match.state == models.MATCH_FINISHED
+}
+
+//Start starts the match clock
+func (match *MatchClock) Start() error {
+
+	match.mutex.Lock()
+	if match.state != models.MATCH_WAITING {
+		match.mutex.Unlock()
+		if match.state == models.MATCH_FINISHED {
+			return ErrMatchFinished
+		}
+		return ErrMatchRunning
+	}
+	match.state = models.MATCH_RUNNING
+	match.startedAt = time.Now()
+	match.generation++
+	generation := match.generation
+	match.timer = time.AfterFunc(match.duration, func() { match.expire(generation) })
+	duration := match.duration
+	match.mutex.Unlock()
+
+	log.Printf("[%s] %s : %s", utilities.CreateColorString("MATCH", color.FgHiGreen), "Match started", duration)
+	if _, ok := match.robot.GetStartPosition(); ok {
+		match.robot.SetStartPosition(match.robot.GetPosition())
+	}
+	match.notify()
+	return nil
+}
+
+//Reset stops the match clock and enables the motion commands again
+func (match *MatchClock) Reset() {
+
+	match.mutex.Lock()
+	if match.timer != nil {
+		match.timer.Stop()
+		match.timer = nil
+	}
+	match.generation++
+	match.state = models.MATCH_WAITING
+	match.mutex.Unlock()
+
+	log.Printf("[%s] %s", utilities.CreateColorString("MATCH", color.FgHiGreen), "Match clock reset")
+	match.notify()
+}
+
+//expire ends the match started with the given generation and stops the robot,
+//the timer can fire while Reset is waiting for the mutex so the match may already be another one
+func (match *MatchClock) expire(generation uint64) {
+
+	match.mutex.Lock()
+	if match.state != models.MATCH_RUNNING || match.generation != generation {
+		match.mutex.Unlock()
+		return
+	}
+	match.state = models.MATCH_FINISHED
+	match.finishedAt = time.Now()
+	match.timer = nil
+	match.mutex.Unlock()
+
+	log.Printf("[%s] %s", utilities.CreateColorString("MATCH", color.FgHiRed), "Match finished: stopping the motors")
+	match.robot.Planner.Cancel()
+	match.robot.Path.Cancel()
+	match.robot.StopMotors()
+	match.notify()
+}
+
+//notify calls the callback with the current status
+func (match *MatchClock) notify() {
+	match.mutex.RLock()
+	callback := match.callback
+	match.mutex.RUnlock()
+
+	if callback != nil {
+		callback(match.Status())
+	}
+}
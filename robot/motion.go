@@ -0,0 +1,332 @@
+package robot
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+const (
+	GOAL_CMD_SET_POSITION      = "set_position"
+	GOAL_CMD_FORWARD_DISTANCE  = "forward_distance"
+	GOAL_CMD_FORWARD_TO_POINT  = "forward_to_point"
+	GOAL_CMD_RELATIVE_ROTATION = "relative_rotation"
+	GOAL_CMD_ABSOLUTE_ROTATION = "absolute_rotation"
+	GOAL_CMD_STOP              = "stop"
+	GOAL_CMD_BRAKE             = "brake"
+
+	GOAL_TOLERANCE       = 30 //mm
+	GOAL_ANGLE_TOLERANCE = 2  //degrees
+	GOAL_SETTLE_TIME     = 300 * time.Millisecond
+	GOAL_START_TIMEOUT   = 2 * time.Second
+	GOAL_TIMEOUT         = 30 * time.Second
+	GOAL_HISTORY         = 64
+)
+
+//MotionHandle tracks a motion command until the robot reports its completion
+type MotionHandle struct {
+	mutex sync.RWMutex
+	goal  models.MotionGoal
+	done  chan struct{}
+}
+
+//ID returns the command identifier
+func (handle *MotionHandle) ID() uint64 {
+	return handle.goal.ID
+}
+
+//Goal returns the current state of the command
+func (handle *MotionHandle) Goal() models.MotionGoal {
+	handle.mutex.RLock()
+	defer handle.mutex.RUnlock()
+	return handle.goal
+}
+
+//Done returns a channel closed when the command is finished
+func (handle *MotionHandle) Done() <-chan struct{} {
+	return handle.done
+}
+
+//Wait blocks until the command is finished or the timeout expires.
+//It returns the state of the command and false if it is still running.
+func (handle *MotionHandle) Wait(timeout time.Duration) (models.MotionGoal, bool) {
+	select {
+	case <-handle.done:
+		return handle.Goal(), true
+	case <-time.After(timeout):
+		return handle.Goal(), false
+	}
+}
+
+//setState changes the state of the command, it returns false if the command was already finished
+func (handle *MotionHandle) setState(state string, err string) bool {
+	handle.mutex.Lock()
+	defer handle.mutex.Unlock()
+
+	if !handle.goal.Active() || handle.goal.State == state {
+		return false
+	}
+	handle.goal.State = state
+	handle.goal.Error = err
+	if !handle.goal.Active() {
+		now := time.Now()
+		handle.goal.FinishedAt = &now
+		close(handle.done)
+	}
+	return true
+}
+
+//startGoal tracks the target of a motion command, pre-empting the previous one.
+//It is called once the command is sent, so a refused command doesn't pre-empt the current goal.
+func (robot *Robot) startGoal(command string, target models.Position) *MotionHandle {
+
+	robot.mutex.Lock()
+	if robot.currentHandle != nil {
+		robot.setGoalState(robot.currentHandle, models.GOAL_PREEMPTED, "pre-empted by "+command)
+	}
+
+	robot.lastHandleID++
+	handle := &MotionHandle{
+		goal: models.MotionGoal{
+			ID:        robot.lastHandleID,
+			Command:   command,
+			Target:    target,
+			State:     models.GOAL_PENDING,
+			StartedAt: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+
+	robot.currentHandle = handle
+	robot.handles = append(robot.handles, handle)
+	if len(robot.handles) > GOAL_HISTORY {
+		robot.handles = robot.handles[len(robot.handles)-GOAL_HISTORY:]
+	}
+	robot.state.Goal = handle.goal
+	robot.state.Sequence++
+	robot.state.Timestamp = time.Now()
+	robot.mutex.Unlock()
+
+	//the goal is checked even if the robot stops sending frames
+	time.AfterFunc(GOAL_SETTLE_TIME, robot.checkGoal)
+	time.AfterFunc(GOAL_START_TIMEOUT, robot.checkGoal)
+	time.AfterFunc(GOAL_TIMEOUT, robot.checkGoal)
+	return handle
+}
+
+//setGoalState changes the state of the command, the robot mutex must be locked
+func (robot *Robot) setGoalState(handle *MotionHandle, state string, err string) {
+
+	if !handle.setState(state, err) {
+		return
+	}
+
+	goal := handle.Goal()
+	switch goal.State {
+	case models.GOAL_REACHED:
+		printInfo("Goal " + goal.Command + " reached")
+	case models.GOAL_RUNNING:
+	default:
+		printError("Goal " + goal.Command + " " + goal.State + ": " + goal.Error)
+	}
+
+	if handle == robot.currentHandle {
+		robot.state.Goal = goal
+		robot.state.Sequence++
+		robot.state.Timestamp = time.Now()
+	}
+	if robot.callbackGoalUpdate != nil {
+		robot.queueGoalUpdate(goal)
+	}
+}
+
+//queueGoalUpdate adds the goal to the updates sent to the callback.
+//The updates are queued while the robot mutex is locked, so they keep the order of the state changes,
+//and a single goroutine calls the callback without holding the lock
+func (robot *Robot) queueGoalUpdate(goal models.MotionGoal) {
+
+	robot.goalUpdatesMutex.Lock()
+	defer robot.goalUpdatesMutex.Unlock()
+
+	robot.goalUpdates = append(robot.goalUpdates, goal)
+	if !robot.goalUpdatesRunning {
+		robot.goalUpdatesRunning = true
+		go robot.sendGoalUpdates()
+	}
+}
+
+//sendGoalUpdates calls the callback with the queued updates until the queue is empty
+func (robot *Robot) sendGoalUpdates() {
+
+	for {
+		robot.goalUpdatesMutex.Lock()
+		if len(robot.goalUpdates) == 0 {
+			robot.goalUpdatesRunning = false
+			robot.goalUpdatesMutex.Unlock()
+			return
+		}
+		goal := robot.goalUpdates[0]
+		robot.goalUpdates = robot.goalUpdates[1:]
+		robot.goalUpdatesMutex.Unlock()
+
+		robot.mutex.RLock()
+		callback := robot.callbackGoalUpdate
+		robot.mutex.RUnlock()
+		if callback != nil {
+			callback(goal)
+		}
+	}
+}
+
+//checkGoal updates the state of the current command using the last status and position
+func (robot *Robot) checkGoal() {
+
+	robot.mutex.Lock()
+	defer robot.mutex.Unlock()
+
+	handle := robot.currentHandle
+	if handle == nil {
+		return
+	}
+	goal := handle.Goal()
+	if !goal.Active() {
+		return
+	}
+
+	state, err := evaluateGoal(goal, robot.state.Status, robot.state.Position, time.Since(goal.StartedAt))
+	if state != goal.State {
+		robot.setGoalState(handle, state, err)
+	}
+}
+
+//evaluateGoal returns the new state of the goal (and the error) using the robot feedback
+func evaluateGoal(goal models.MotionGoal, status models.RobotStatus, position models.Position, elapsed time.Duration) (string, string) {
+
+	near := goalNear(goal, position)
+
+	switch {
+	case status.Error || status.ErrorCode != 0:
+		return models.GOAL_FAILED, "robot error"
+	case elapsed >= GOAL_TIMEOUT:
+		return models.GOAL_TIMEOUT, "the robot did not complete the command"
+	}
+
+	switch goal.Command {
+	case GOAL_CMD_SET_POSITION:
+		if near {
+			return models.GOAL_REACHED, ""
+		}
+		if elapsed >= GOAL_START_TIMEOUT {
+			return models.GOAL_FAILED, "position not applied"
+		}
+		return goal.State, ""
+	case GOAL_CMD_STOP, GOAL_CMD_BRAKE:
+		if !status.Moving && elapsed >= GOAL_SETTLE_TIME {
+			return models.GOAL_REACHED, ""
+		}
+		return goal.State, ""
+	}
+
+	if goal.State == models.GOAL_RUNNING {
+		switch {
+		case status.Blocked:
+			return models.GOAL_BLOCKED, "robot blocked"
+		case status.TargetReached || (!status.Moving && near):
+			return models.GOAL_REACHED, ""
+		}
+		return goal.State, ""
+	}
+
+	switch {
+	case status.Moving:
+		return models.GOAL_RUNNING, ""
+	case near && elapsed >= GOAL_SETTLE_TIME:
+		//the robot was already on the target
+		return models.GOAL_REACHED, ""
+	case elapsed >= GOAL_START_TIMEOUT && status.Blocked:
+		return models.GOAL_BLOCKED, "robot blocked"
+	case elapsed >= GOAL_START_TIMEOUT:
+		return models.GOAL_FAILED, "the robot did not start"
+	}
+	return goal.State, ""
+}
+
+//goalNear returns true if the position is within the tolerance of the goal target
+func goalNear(goal models.MotionGoal, position models.Position) bool {
+	angleNear := math.Abs(angleDifference(goal.Target.Angle, position.Angle)) <= GOAL_ANGLE_TOLERANCE
+	//the coordinates are converted before subtracting, the int16 difference can overflow
+	dx := float64(goal.Target.X) - float64(position.X)
+	dy := float64(goal.Target.Y) - float64(position.Y)
+	pointNear := math.Hypot(dx, dy) <= GOAL_TOLERANCE
+
+	switch goal.Command {
+	case GOAL_CMD_RELATIVE_ROTATION, GOAL_CMD_ABSOLUTE_ROTATION:
+		return angleNear
+	case GOAL_CMD_SET_POSITION:
+		return angleNear && pointNear
+	}
+	return pointNear
+}
+
+//normalizeAngle returns the angle in the range (-180, 180]
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle, 360)
+	if angle > 180 {
+		angle -= 360
+	} else if angle <= -180 {
+		angle += 360
+	}
+	return angle
+}
+
+//angleDifference returns the shortest rotation (in degrees) going from the angle "from" to the angle "to"
+func angleDifference(to int16, from int16) float64 {
+	return normalizeAngle(float64(to) - float64(from))
+}
+
+//clampInt16 rounds the value to the nearest int16
+func clampInt16(value float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(value))))
+}
+
+//SetCallbackGoalUpdate set the function called when the state of a motion command changes
+func (robot *Robot) SetCallbackGoalUpdate(cb func(goal models.MotionGoal)) {
+	robot.mutex.Lock()
+	defer robot.mutex.Unlock()
+	robot.callbackGoalUpdate = cb
+}
+
+//GetGoal returns the last motion goal, false if no goal was started
+func (robot *Robot) GetGoal() (models.MotionGoal, bool) {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
+
+	return robot.state.Goal, robot.state.Goal.State != ""
+}
+
+//GetCommand returns the handle of a recent motion command
+func (robot *Robot) GetCommand(id uint64) (*MotionHandle, bool) {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
+
+	for _, handle := range robot.handles {
+		if handle.ID() == id {
+			return handle, true
+		}
+	}
+	return nil, false
+}
+
+//GetCommands returns the state of the recent motion commands, the most recent first
+func (robot *Robot) GetCommands() []models.MotionGoal {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
+
+	goals := make([]models.MotionGoal, 0, len(robot.handles))
+	for i := len(robot.handles) - 1; i >= 0; i-- {
+		goals = append(goals, robot.handles[i].Goal())
+	}
+	return goals
+}
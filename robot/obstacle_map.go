@@ -0,0 +1,100 @@
+package robot
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+const (
+	OBSTACLE_EXPIRY     = 1 * time.Second
+	OBSTACLE_MIN_RADIUS = 50
+)
+
+//ObstacleMap keeps the last obstacles received from the robot, keyed by obstacle number.
+//An obstacle not received again within Expiry is considered gone.
+type ObstacleMap struct {
+	Expiry    time.Duration
+	mutex     sync.RWMutex
+	obstacles map[uint8]models.Obstacle
+}
+
+//NewObstacleMap returns an empty ObstacleMap
+func NewObstacleMap(expiry time.Duration) *ObstacleMap {
+	return &ObstacleMap{
+		Expiry:    expiry,
+		obstacles: make(map[uint8]models.Obstacle),
+	}
+}
+
+//Update stores the obstacle map entry seen from the given robot position.
+//The angular sector (relative to the robot heading) is converted into field coordinates.
+func (obstacleMap *ObstacleMap) Update(frame models.ObstacleFrame, position models.Position) models.Obstacle {
+
+	now := time.Now()
+	obstacle := models.Obstacle{
+		Number:     frame.Number,
+		Valid:      frame.Valid != 0,
+		AngleStart: frame.AngleStart,
+		AngleEnd:   frame.AngleEnd,
+		Distance:   frame.Distance,
+		LastSeen:   now,
+		Expires:    now.Add(obstacleMap.Expiry),
+	}
+
+	//sector width in [0, 360) and center, the sector goes counterclockwise from AngleStart to AngleEnd
+	width := math.Mod(float64(frame.AngleEnd)-float64(frame.AngleStart), 360)
+	if width < 0 {
+		width += 360
+	}
+	center := float64(position.Angle) + float64(frame.AngleStart) + width/2
+	radians := center * math.Pi / 180
+	distance := float64(frame.Distance)
+
+	//a sector wider than 180 degrees surrounds the robot, the radius is at most the distance
+	halfWidth := math.Min(width/2, 90)
+	obstacle.X = clampInt16(float64(position.X) + distance*math.Cos(radians))
+	obstacle.Y = clampInt16(float64(position.Y) + distance*math.Sin(radians))
+	obstacle.Radius = clampInt16(math.Max(OBSTACLE_MIN_RADIUS, distance*math.Sin(halfWidth*math.Pi/180)))
+
+	obstacleMap.mutex.Lock()
+	obstacleMap.obstacles[frame.Number] = obstacle
+	obstacleMap.mutex.Unlock()
+
+	return obstacle
+}
+
+//Obstacles returns the valid and not expired obstacles ordered by number
+func (obstacleMap *ObstacleMap) Obstacles() []models.Obstacle {
+	return obstacleMap.list(false)
+}
+
+//AllObstacles returns every obstacle received, including the invalid and expired ones
+func (obstacleMap *ObstacleMap) AllObstacles() []models.Obstacle {
+	return obstacleMap.list(true)
+}
+
+//Clear removes all obstacles
+func (obstacleMap *ObstacleMap) Clear() {
+	obstacleMap.mutex.Lock()
+	defer obstacleMap.mutex.Unlock()
+	obstacleMap.obstacles = make(map[uint8]models.Obstacle)
+}
+
+func (obstacleMap *ObstacleMap) list(all bool) []models.Obstacle {
+	obstacleMap.mutex.RLock()
+	defer obstacleMap.mutex.RUnlock()
+
+	now := time.Now()
+	obstacles := make([]models.Obstacle, 0, len(obstacleMap.obstacles))
+	for _, obstacle := range obstacleMap.obstacles {
+		if all || (obstacle.Valid && now.Before(obstacle.Expires)) {
+			obstacles = append(obstacles, obstacle)
+		}
+	}
+	sort.Slice(obstacles, func(i, j int) bool { return obstacles[i].Number < obstacles[j].Number })
+	return obstacles
+}
@@ -0,0 +1,331 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/utilities"
+	"github.com/fatih/color"
+)
+
+const (
+	PATH_CHECK_PERIOD = 20 * time.Millisecond
+
+	pathPause  = "pause"
+	pathResume = "resume"
+	pathCancel = "cancel"
+)
+
+var (
+	ErrPathEmpty     = errors.New("the path has no waypoints")
+	ErrPathActive    = errors.New("a path is already running")
+	ErrPathNotActive = errors.New("no path is running")
+	ErrPathBusy      = errors.New("the path is processing another request")
+)
+
+//PathExecutor moves the robot through an ordered list of waypoints.
+//Every waypoint is reached with a forward to point command (followed by an absolute rotation if it has a heading),
+//the next waypoint is sent as soon as the robot is within the waypoint tolerance.
+type PathExecutor struct {
+	robot    *Robot
+	mutex    sync.RWMutex
+	status   models.PathStatus
+	control  chan string
+	done     chan struct{}
+	callback func(status models.PathStatus)
+}
+
+//NewPathExecutor returns an idle PathExecutor for the given robot
+func NewPathExecutor(robot *Robot) *PathExecutor {
+	done := make(chan struct{})
+	close(done)
+	return &PathExecutor{
+		robot:  robot,
+		status: models.PathStatus{State: models.PATH_IDLE, Waypoints: []models.Waypoint{}},
+		done:   done,
+	}
+}
+
+//SetCallbackUpdate set the function called when the state or the progress of the path changes
+func (path *PathExecutor) SetCallbackUpdate(cb func(status models.PathStatus)) {
+	path.mutex.Lock()
+	defer path.mutex.Unlock()
+	path.callback = cb
+}
+
+//Status returns the progress of the current (or last) path
+func (path *PathExecutor) Status() models.PathStatus {
+	path.mutex.RLock()
+	defer path.mutex.RUnlock()
+	return path.status
+}
+
+//Done returns a channel closed when the current (or last) path is finished
+func (path *PathExecutor) Done() <-chan struct{} {
+	path.mutex.RLock()
+	defer path.mutex.RUnlock()
+	return path.done
+}
+
+//Start starts moving through the given waypoints
+func (path *PathExecutor) Start(waypoints []models.Waypoint) error {
+
+	if len(waypoints) == 0 {
+		return ErrPathEmpty
+	}
+	if path.robot.Match.Finished() {
+		return ErrMatchFinished
+	}
+	for i, waypoint := range waypoints {
+		if err := path.robot.CheckGeofence(waypoint.X, waypoint.Y); err != nil {
+			return fmt.Errorf("waypoint %d: %w", i, err)
+		}
+	}
+
+	path.mutex.Lock()
+	if path.status.Active() {
+		path.mutex.Unlock()
+		return ErrPathActive
+	}
+	now := time.Now()
+	path.status = models.PathStatus{
+		State:     models.PATH_RUNNING,
+		Waypoints: append([]models.Waypoint{}, waypoints...),
+		StartedAt: &now,
+	}
+	path.control = make(chan string, 4)
+	path.done = make(chan struct{})
+	control := path.control
+	path.mutex.Unlock()
+
+	log.Printf("[%s] %s : %d waypoints", utilities.CreateColorString("PATH", color.FgHiCyan), "Path started", len(waypoints))
+	path.notify()
+	go path.run(waypoints, control)
+	return nil
+}
+
+//Pause stops the robot, the path can be continued with Resume
+func (path *PathExecutor) Pause() error {
+	return path.request(pathPause, models.PATH_RUNNING)
+}
+
+//Resume continues a paused path from the current waypoint
+func (path *PathExecutor) Resume() error {
+	return path.request(pathResume, models.PATH_PAUSED)
+}
+
+//Cancel stops the robot and terminates the path
+func (path *PathExecutor) Cancel() error {
+	return path.request(pathCancel, "")
+}
+
+//request sends the control request to the running path if it is in the given state ("" for any active state)
+func (path *PathExecutor) request(request string, state string) error {
+	path.mutex.RLock()
+	defer path.mutex.RUnlock()
+
+	if !path.status.Active() || (state != "" && path.status.State != state) {
+		return ErrPathNotActive
+	}
+	select {
+	case path.control <- request:
+		return nil
+	default:
+		return ErrPathBusy
+	}
+}
+
+//run executes the waypoints, it returns when the path is completed, cancelled or failed
+func (path *PathExecutor) run(waypoints []models.Waypoint, control chan string) {
+
+	index := 0
+	for index < len(waypoints) {
+
+		path.update(func(status *models.PathStatus) {
+			status.Current = index
+		})
+
+		request, err := path.execute(waypoints[index], index == len(waypoints)-1, control)
+		if err != nil {
+			path.finish(models.PATH_FAILED, err)
+			return
+		}
+
+		switch request {
+		case "":
+			index++
+			path.update(func(status *models.PathStatus) {
+				status.Completed = index
+				status.Progress = float64(index) / float64(len(waypoints))
+			})
+		case pathPause:
+			path.stop()
+			path.update(func(status *models.PathStatus) {
+				status.State = models.PATH_PAUSED
+			})
+			log.Printf("[%s] %s", utilities.CreateColorString("PATH", color.FgHiCyan), "Path paused")
+			if path.waitResume(control) == pathCancel {
+				path.finish(models.PATH_CANCELLED, nil)
+				return
+			}
+			path.update(func(status *models.PathStatus) {
+				status.State = models.PATH_RUNNING
+			})
+			log.Printf("[%s] %s", utilities.CreateColorString("PATH", color.FgHiCyan), "Path resumed")
+		case pathCancel:
+			path.stop()
+			path.finish(models.PATH_CANCELLED, nil)
+			return
+		}
+	}
+	path.finish(models.PATH_COMPLETED, nil)
+}
+
+//execute moves the robot to the waypoint, it returns the control request received meanwhile
+func (path *PathExecutor) execute(waypoint models.Waypoint, last bool, control chan string) (string, error) {
+
+	if waypoint.Speed > 0 {
+		if err := path.robot.SetSpeed(waypoint.Speed); err != nil {
+			return "", err
+		}
+	}
+
+	tolerance := float64(waypoint.Tolerance)
+	if tolerance <= 0 {
+		tolerance = GOAL_TOLERANCE
+	}
+	arrived := func(position models.Position) bool {
+		return math.Hypot(float64(waypoint.X)-float64(position.X), float64(waypoint.Y)-float64(position.Y)) <= tolerance
+	}
+
+	handle, err := path.robot.ForwardToPoint(waypoint.X, waypoint.Y)
+	if err != nil {
+		return "", err
+	}
+	//the robot passes through the intermediate waypoints without stopping
+	passThrough := !last && waypoint.Angle == nil
+	if request, err := path.wait(handle, control, arrived, passThrough); request != "" || err != nil {
+		return request, err
+	}
+
+	if waypoint.Angle != nil {
+		handle, err = path.robot.AbsoluteRotation(*waypoint.Angle)
+		if err != nil {
+			return "", err
+		}
+		return path.wait(handle, control, nil, false)
+	}
+	return "", nil
+}
+
+//wait waits for the end of the motion command, it returns early if a control request is received
+//or, when passThrough is true, as soon as the robot has arrived
+func (path *PathExecutor) wait(handle *MotionHandle, control chan string, arrived func(models.Position) bool, passThrough bool) (string, error) {
+
+	ticker := time.NewTicker(PATH_CHECK_PERIOD)
+	defer ticker.Stop()
+
+	for {
+		goal := handle.Goal()
+		path.update(func(status *models.PathStatus) {
+			status.Goal = &goal
+		})
+
+		select {
+		case <-handle.Done():
+			goal = handle.Goal()
+			path.update(func(status *models.PathStatus) {
+				status.Goal = &goal
+			})
+			if goal.State == models.GOAL_REACHED || (arrived != nil && arrived(path.robot.GetPosition())) {
+				return "", nil
+			}
+			return "", errors.New(goal.Command + " " + goal.State + ": " + goal.Error)
+		case request := <-control:
+			if request == pathResume {
+				continue
+			}
+			return request, nil
+		case <-ticker.C:
+			if passThrough && arrived(path.robot.GetPosition()) {
+				return "", nil
+			}
+		}
+	}
+}
+
+//stop stops the motors, the stop command becomes the current goal of the path
+func (path *PathExecutor) stop() {
+	handle, err := path.robot.StopMotors()
+	if err != nil {
+		return
+	}
+	goal := handle.Goal()
+	path.update(func(status *models.PathStatus) {
+		status.Goal = &goal
+	})
+}
+
+//waitResume waits until the paused path is resumed or cancelled
+func (path *PathExecutor) waitResume(control chan string) string {
+	for {
+		request := <-control
+		if request != pathPause {
+			return request
+		}
+	}
+}
+
+//update changes the path status, the callback is called only if the state or the progress changed
+func (path *PathExecutor) update(change func(status *models.PathStatus)) {
+
+	path.mutex.Lock()
+	previous := path.status
+	change(&path.status)
+	changed := previous.State != path.status.State || previous.Current != path.status.Current || previous.Completed != path.status.Completed
+	path.mutex.Unlock()
+
+	if changed {
+		path.notify()
+	}
+}
+
+//finish terminates the path with the given state
+func (path *PathExecutor) finish(state string, err error) {
+
+	path.update(func(status *models.PathStatus) {
+		status.State = state
+		if err != nil {
+			status.Error = err.Error()
+		}
+		now := time.Now()
+		status.FinishedAt = &now
+	})
+
+	path.mutex.RLock()
+	close(path.done)
+	path.mutex.RUnlock()
+
+	if err != nil {
+		log.Printf("[%s] %s : %s", utilities.CreateColorString("PATH", color.FgHiRed), "Path "+state, err)
+	} else {
+		log.Printf("[%s] %s", utilities.CreateColorString("PATH", color.FgHiCyan), "Path "+state)
+	}
+}
+
+//notify calls the callback with the current status
+func (path *PathExecutor) notify() {
+	path.mutex.RLock()
+	callback := path.callback
+	status := path.status
+	path.mutex.RUnlock()
+
+	if callback != nil {
+		callback(status)
+	}
+}
@@ -0,0 +1,489 @@
+package robot
+
+import (
+	"container/heap"
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/utilities"
+	"github.com/fatih/color"
+)
+
+const (
+	PLANNER_RESOLUTION       = 50 //mm
+	PLANNER_TOLERANCE        = 60 //mm, tolerance of the intermediate waypoints
+	PLANNER_MARGIN           = 50 //mm, clearance added when planning, so that small deviations do not block the path
+	PLANNER_CHECK_PERIOD     = 100 * time.Millisecond
+	PLANNER_MAX_REPLANS      = 10
+	PLANNER_OPPONENT_TIMEOUT = 1 * time.Second
+)
+
+var (
+	ErrPlanGoalBlocked = errors.New("the goal is not reachable: outside the field or inside an obstacle")
+	ErrPlanNoPath      = errors.New("no collision-free path to the goal")
+	ErrPlanActive      = errors.New("the robot is already moving to a goal")
+	ErrPlanNotActive   = errors.New("the robot is not moving to a goal")
+)
+
+//Planner computes collision-free paths on the field and executes them with the PathExecutor.
+//While the robot moves, the remaining path is checked against the obstacles and computed again if it is blocked.
+type Planner struct {
+	robot    *Robot
+	mutex    sync.RWMutex
+	status   models.PlanStatus
+	stop     chan struct{}
+	callback func(status models.PlanStatus)
+}
+
+//NewPlanner returns an idle Planner for the given robot
+func NewPlanner(robot *Robot) *Planner {
+	return &Planner{
+		robot:  robot,
+		status: models.PlanStatus{State: models.PLAN_IDLE, Waypoints: []models.Waypoint{}},
+	}
+}
+
+//SetCallbackUpdate set the function called when the state of the planned motion changes
+func (planner *Planner) SetCallbackUpdate(cb func(status models.PlanStatus)) {
+	planner.mutex.Lock()
+	defer planner.mutex.Unlock()
+	planner.callback = cb
+}
+
+//Status returns the progress of the current (or last) planned motion
+func (planner *Planner) Status() models.PlanStatus {
+	planner.mutex.RLock()
+	defer planner.mutex.RUnlock()
+	return planner.status
+}
+
+//Plan returns the path from the current position to the goal, without moving the robot
+func (planner *Planner) Plan(goal models.Waypoint) ([]models.Waypoint, error) {
+	if err := planner.robot.CheckGeofence(goal.X, goal.Y); err != nil {
+		return nil, err
+	}
+	return planner.robot.planPath(planner.robot.GetPosition(), goal)
+}
+
+//Start moves the robot to the goal through a collision-free path
+func (planner *Planner) Start(goal models.Waypoint) error {
+
+	if err := planner.robot.CheckGeofence(goal.X, goal.Y); err != nil {
+		return err
+	}
+	if planner.robot.Match.Finished() {
+		return ErrMatchFinished
+	}
+
+	planner.mutex.Lock()
+	if planner.status.Active() {
+		planner.mutex.Unlock()
+		return ErrPlanActive
+	}
+	now := time.Now()
+	planner.status = models.PlanStatus{
+		State:     models.PLAN_RUNNING,
+		Goal:      goal,
+		Waypoints: []models.Waypoint{},
+		StartedAt: &now,
+	}
+	planner.stop = make(chan struct{})
+	stop := planner.stop
+	planner.mutex.Unlock()
+
+	waypoints, err := planner.robot.planPath(planner.robot.GetPosition(), goal)
+	if err == nil {
+		err = planner.robot.Path.Start(waypoints)
+	}
+	if err != nil {
+		planner.finish(models.PLAN_FAILED, err)
+		return err
+	}
+
+	planner.update(func(status *models.PlanStatus) {
+		status.Waypoints = waypoints
+	})
+	log.Printf("[%s] %s : X: %d, Y: %d, %d waypoints", utilities.CreateColorString("PLANNER", color.FgHiCyan), "Moving to goal", goal.X, goal.Y, len(waypoints))
+
+	go planner.run(goal, stop)
+	return nil
+}
+
+//Cancel stops the robot and terminates the planned motion
+func (planner *Planner) Cancel() error {
+	planner.mutex.Lock()
+	defer planner.mutex.Unlock()
+
+	if !planner.status.Active() || planner.stop == nil {
+		return ErrPlanNotActive
+	}
+	close(planner.stop)
+	planner.stop = nil
+	return nil
+}
+
+//run follows the path execution, computing the path again when it is blocked
+func (planner *Planner) run(goal models.Waypoint, stop chan struct{}) {
+
+	ticker := time.NewTicker(PLANNER_CHECK_PERIOD)
+	defer ticker.Stop()
+
+	for replans := 0; ; {
+
+		done := planner.robot.Path.Done()
+		reason := ""
+		for reason == "" {
+			select {
+			case <-stop:
+				planner.robot.Path.Cancel()
+				<-done
+				planner.finish(models.PLAN_CANCELLED, nil)
+				return
+			case <-done:
+				path := planner.robot.Path.Status()
+				switch path.State {
+				case models.PATH_COMPLETED:
+					planner.finish(models.PLAN_REACHED, nil)
+					return
+				case models.PATH_CANCELLED:
+					planner.finish(models.PLAN_CANCELLED, nil)
+					return
+				}
+				reason = path.Error
+			case <-ticker.C:
+				path := planner.robot.Path.Status()
+				if path.State == models.PATH_RUNNING && planner.robot.pathBlocked(planner.robot.GetPosition(), path.Waypoints[path.Current:]) {
+					planner.robot.Path.Cancel()
+					<-done
+					reason = "path blocked by an obstacle"
+				}
+			}
+		}
+
+		replans++
+		if replans > PLANNER_MAX_REPLANS {
+			planner.finish(models.PLAN_FAILED, errors.New("too many replans: "+reason))
+			return
+		}
+		log.Printf("[%s] %s : %s", utilities.CreateColorString("PLANNER", color.FgHiYellow), "Replanning", reason)
+
+		waypoints, err := planner.robot.planPath(planner.robot.GetPosition(), goal)
+		if err == nil {
+			err = planner.robot.Path.Start(waypoints)
+		}
+		if err != nil {
+			planner.finish(models.PLAN_FAILED, err)
+			return
+		}
+		planner.update(func(status *models.PlanStatus) {
+			status.Waypoints = waypoints
+			status.Replans = replans
+		})
+	}
+}
+
+//update changes the status and calls the callback
+func (planner *Planner) update(change func(status *models.PlanStatus)) {
+
+	planner.mutex.Lock()
+	change(&planner.status)
+	status := planner.status
+	callback := planner.callback
+	planner.mutex.Unlock()
+
+	if callback != nil {
+		callback(status)
+	}
+}
+
+//finish terminates the planned motion with the given state
+func (planner *Planner) finish(state string, err error) {
+
+	planner.update(func(status *models.PlanStatus) {
+		status.State = state
+		if err != nil {
+			status.Error = err.Error()
+		}
+		now := time.Now()
+		status.FinishedAt = &now
+		planner.stop = nil
+	})
+
+	if err != nil {
+		log.Printf("[%s] %s : %s", utilities.CreateColorString("PLANNER", color.FgHiRed), "Goal "+state, err)
+	} else {
+		log.Printf("[%s] %s", utilities.CreateColorString("PLANNER", color.FgHiCyan), "Goal "+state)
+	}
+}
+
+//planPath returns the waypoints of a collision-free path from the start position to the goal.
+//The path keeps PLANNER_MARGIN from the obstacles when the free space allows it.
+func (robot *Robot) planPath(start models.Position, goal models.Waypoint) ([]models.Waypoint, error) {
+
+	waypoints, err := robot.planPathWithMargin(start, goal, PLANNER_MARGIN)
+	if err != nil {
+		waypoints, err = robot.planPathWithMargin(start, goal, 0)
+	}
+	return waypoints, err
+}
+
+//planPathWithMargin returns the waypoints of a path keeping the given margin from the obstacles
+func (robot *Robot) planPathWithMargin(start models.Position, goal models.Waypoint, margin float64) ([]models.Waypoint, error) {
+
+	grid := robot.occupancyGrid(margin)
+
+	goalX, goalY, inside := grid.cell(float64(goal.X), float64(goal.Y))
+	if !inside || grid.isBlocked(goalX, goalY) {
+		return nil, ErrPlanGoalBlocked
+	}
+	startX, startY, inside := grid.cell(float64(start.X), float64(start.Y))
+	if !inside {
+		return nil, errors.New("the robot is outside the field")
+	}
+
+	cells, found := grid.findPath(startX, startY, goalX, goalY)
+	if !found {
+		return nil, ErrPlanNoPath
+	}
+
+	points := make([]models.Point, len(cells))
+	for i, c := range cells {
+		x, y := grid.center(c[0], c[1])
+		points[i] = models.Point{X: int16(math.Round(x)), Y: int16(math.Round(y))}
+	}
+	points[0] = models.Point{X: start.X, Y: start.Y}
+	points = append(points[:len(points)-1], models.Point{X: goal.X, Y: goal.Y})
+
+	//only the points needed to avoid the obstacles are kept
+	waypoints := []models.Waypoint{}
+	for anchor := 0; anchor < len(points)-1; {
+		next := len(points) - 1
+		for next > anchor+1 && !grid.lineFree(points[anchor], points[next], anchor == 0) {
+			next--
+		}
+		waypoints = append(waypoints, models.Waypoint{X: points[next].X, Y: points[next].Y, Tolerance: PLANNER_TOLERANCE})
+		anchor = next
+	}
+	if len(waypoints) == 0 {
+		waypoints = append(waypoints, models.Waypoint{X: goal.X, Y: goal.Y})
+	}
+
+	waypoints[0].Speed = goal.Speed
+	last := &waypoints[len(waypoints)-1]
+	last.Angle = goal.Angle
+	last.Tolerance = goal.Tolerance
+	return waypoints, nil
+}
+
+//pathBlocked returns true if an obstacle blocks the path going from the position through the waypoints
+func (robot *Robot) pathBlocked(position models.Position, waypoints []models.Waypoint) bool {
+
+	grid := robot.occupancyGrid(0)
+	from := models.Point{X: position.X, Y: position.Y}
+	for i, waypoint := range waypoints {
+		to := models.Point{X: waypoint.X, Y: waypoint.Y}
+		if !grid.lineFree(from, to, i == 0) {
+			return true
+		}
+		from = to
+	}
+	return false
+}
+
+//occupancyGrid returns the field cells the robot can not occupy:
+//the ones near the table boundary, the static field obstacles, the obstacle map and the opponent
+//(enlarged by the robot radius plus the given margin) and the forbidden zones (enlarged by the margin).
+func (robot *Robot) occupancyGrid(margin float64) *occupancyGrid {
+
+	field := robot.GetField()
+	robotRadius := float64(field.RobotRadius) + margin
+
+	type circle struct{ x, y, radius float64 }
+	circles := []circle{}
+	for _, obstacle := range robot.Obstacles.Obstacles() {
+		circles = append(circles, circle{float64(obstacle.X), float64(obstacle.Y), float64(obstacle.Radius) + robotRadius})
+	}
+	state := robot.Snapshot()
+	if !state.OtherPositionUpdate.IsZero() && time.Since(state.OtherPositionUpdate) < PLANNER_OPPONENT_TIMEOUT {
+		circles = append(circles, circle{float64(state.OtherPosition.X), float64(state.OtherPosition.Y), float64(field.OpponentRadius) + robotRadius})
+	}
+
+	grid := &occupancyGrid{
+		minX:   float64(field.MinX),
+		minY:   float64(field.MinY),
+		width:  int(math.Ceil((float64(field.MaxX) - float64(field.MinX)) / PLANNER_RESOLUTION)),
+		height: int(math.Ceil((float64(field.MaxY) - float64(field.MinY)) / PLANNER_RESOLUTION)),
+	}
+	grid.clearance = make([]float64, grid.width*grid.height)
+
+	for cy := 0; cy < grid.height; cy++ {
+		for cx := 0; cx < grid.width; cx++ {
+			x, y := grid.center(cx, cy)
+			clearance := math.Min(math.Min(x-float64(field.MinX), float64(field.MaxX)-x),
+				math.Min(y-float64(field.MinY), float64(field.MaxY)-y)) - robotRadius
+			for _, obstacle := range field.Obstacles {
+				clearance = math.Min(clearance, signedPolygonDistance(x, y, obstacle.Polygon)-robotRadius)
+			}
+			for _, zone := range field.Forbidden {
+				clearance = math.Min(clearance, signedPolygonDistance(x, y, zone.Polygon)-margin)
+			}
+			for _, c := range circles {
+				clearance = math.Min(clearance, math.Hypot(x-c.x, y-c.y)-c.radius)
+			}
+			grid.clearance[cy*grid.width+cx] = clearance
+		}
+	}
+	return grid
+}
+
+//occupancyGrid rappresents the field as a grid of PLANNER_RESOLUTION cells,
+//every cell keeps its clearance: the distance the robot can move before touching an obstacle,
+//negative (the deeper the lower) in the blocked cells
+type occupancyGrid struct {
+	minX      float64
+	minY      float64
+	width     int
+	height    int
+	clearance []float64
+}
+
+//cell returns the cell containing the point, false if the point is outside the grid
+func (grid *occupancyGrid) cell(x float64, y float64) (int, int, bool) {
+	cx := int(math.Floor((x - grid.minX) / PLANNER_RESOLUTION))
+	cy := int(math.Floor((y - grid.minY) / PLANNER_RESOLUTION))
+	return cx, cy, cx >= 0 && cy >= 0 && cx < grid.width && cy < grid.height
+}
+
+//center returns the field coordinates of the cell center
+func (grid *occupancyGrid) center(cx int, cy int) (float64, float64) {
+	return grid.minX + (float64(cx)+0.5)*PLANNER_RESOLUTION, grid.minY + (float64(cy)+0.5)*PLANNER_RESOLUTION
+}
+
+//cellClearance returns the clearance of the cell, -Inf outside the grid
+func (grid *occupancyGrid) cellClearance(cx int, cy int) float64 {
+	if cx < 0 || cy < 0 || cx >= grid.width || cy >= grid.height {
+		return math.Inf(-1)
+	}
+	return grid.clearance[cy*grid.width+cx]
+}
+
+//isBlocked returns true if the cell is blocked or outside the grid
+func (grid *occupancyGrid) isBlocked(cx int, cy int) bool {
+	return grid.cellClearance(cx, cy) < 0
+}
+
+//lineFree returns true if the segment does not cross blocked cells.
+//With escape, the segment can start in blocked cells (the robot is leaving an obstacle area)
+//as long as their clearance never decreases: the segment can not go deeper into an obstacle
+//nor reach another obstacle before leaving the blocked cells.
+func (grid *occupancyGrid) lineFree(from models.Point, to models.Point, escape bool) bool {
+
+	dx, dy := float64(to.X)-float64(from.X), float64(to.Y)-float64(from.Y)
+	steps := int(math.Ceil(math.Hypot(dx, dy)/(PLANNER_RESOLUTION/4))) + 1
+	previous := math.Inf(-1)
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		cx, cy, _ := grid.cell(float64(from.X)+t*dx, float64(from.Y)+t*dy)
+		clearance := grid.cellClearance(cx, cy)
+		if clearance >= 0 {
+			escape = false
+		} else if !escape || clearance < previous {
+			return false
+		}
+		previous = clearance
+	}
+	return true
+}
+
+//findPath returns the cells of the shortest path (A* on 8-connected cells).
+//The robot can leave the blocked area it starts in only moving to cells with a higher clearance,
+//and it can not enter blocked cells from free ones.
+func (grid *occupancyGrid) findPath(startX int, startY int, goalX int, goalY int) ([][2]int, bool) {
+
+	index := func(cx, cy int) int { return cy*grid.width + cx }
+	heuristic := func(cx, cy int) float64 {
+		dx, dy := math.Abs(float64(cx-goalX)), math.Abs(float64(cy-goalY))
+		return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+	}
+
+	cost := make([]float64, len(grid.clearance))
+	parent := make([]int, len(grid.clearance))
+	for i := range cost {
+		cost[i] = math.Inf(1)
+		parent[i] = -1
+	}
+
+	start, goal := index(startX, startY), index(goalX, goalY)
+	cost[start] = 0
+	open := &cellQueue{{index: start, priority: heuristic(startX, startY)}}
+
+	for open.Len() > 0 {
+		current := heap.Pop(open).(cellItem)
+		if current.index == goal {
+			break
+		}
+		cx, cy := current.index%grid.width, current.index/grid.width
+		if current.priority > cost[current.index]+heuristic(cx, cy)+1e-9 {
+			continue //already expanded with a lower cost
+		}
+		escaping := grid.isBlocked(cx, cy)
+
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := cx+dx, cy+dy
+				if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= grid.width || ny >= grid.height {
+					continue
+				}
+				if escaping && grid.cellClearance(nx, ny) <= grid.cellClearance(cx, cy) {
+					continue
+				}
+				if !escaping && (grid.isBlocked(nx, ny) || (dx != 0 && dy != 0 && (grid.isBlocked(cx+dx, cy) || grid.isBlocked(cx, cy+dy)))) {
+					continue
+				}
+				step := 1.0
+				if dx != 0 && dy != 0 {
+					step = math.Sqrt2
+				}
+				next := index(nx, ny)
+				if cost[current.index]+step < cost[next] {
+					cost[next] = cost[current.index] + step
+					parent[next] = current.index
+					heap.Push(open, cellItem{index: next, priority: cost[next] + heuristic(nx, ny)})
+				}
+			}
+		}
+	}
+
+	if math.IsInf(cost[goal], 1) {
+		return nil, false
+	}
+	cells := [][2]int{}
+	for i := goal; i != -1; i = parent[i] {
+		cells = append([][2]int{{i % grid.width, i / grid.width}}, cells...)
+	}
+	return cells, true
+}
+
+//cellItem is an entry of the A* open set
+type cellItem struct {
+	index    int
+	priority float64
+}
+
+//cellQueue is the A* open set, ordered by priority
+type cellQueue []cellItem
+
+func (queue cellQueue) Len() int            { return len(queue) }
+func (queue cellQueue) Less(i, j int) bool  { return queue[i].priority < queue[j].priority }
+func (queue cellQueue) Swap(i, j int)       { queue[i], queue[j] = queue[j], queue[i] }
+func (queue *cellQueue) Push(x interface{}) { *queue = append(*queue, x.(cellItem)) }
+func (queue *cellQueue) Pop() interface{} {
+	old := *queue
+	item := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,110 @@
+package robot
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+)
+
+//testRobot returns a robot with the field layout and no connection
+func testRobot(field models.Field) *Robot {
+	return &Robot{field: field, Obstacles: NewObstacleMap(time.Second)}
+}
+
+//testField returns the default 3000x2000 field with the given obstacles and forbidden zones
+func testField(obstacles []models.FieldObstacle, forbidden []models.FieldObstacle) models.Field {
+	return models.Field{MinX: 0, MinY: 0, MaxX: 3000, MaxY: 2000, RobotRadius: 150, OpponentRadius: 200, Obstacles: obstacles, Forbidden: forbidden}
+}
+
+//pathPoints returns the points along the path from the start through the waypoints, every 5mm
+func pathPoints(start models.Position, waypoints []models.Waypoint) []models.Point {
+
+	points := []models.Point{}
+	from := models.Point{X: start.X, Y: start.Y}
+	for _, waypoint := range waypoints {
+		to := models.Point{X: waypoint.X, Y: waypoint.Y}
+		dx, dy := float64(to.X)-float64(from.X), float64(to.Y)-float64(from.Y)
+		steps := int(math.Hypot(dx, dy)/5) + 1
+		for i := 0; i <= steps; i++ {
+			t := float64(i) / float64(steps)
+			points = append(points, models.Point{
+				X: int16(float64(from.X) + t*dx),
+				Y: int16(float64(from.Y) + t*dy),
+			})
+		}
+		from = to
+	}
+	return points
+}
+
+func TestPlanPathStartInsideBoundaryBand(t *testing.T) {
+
+	robot := testRobot(testField(nil, nil))
+	tests := []struct {
+		name  string
+		start models.Position
+		goal  models.Waypoint
+	}{
+		{"left edge", models.Position{X: 50, Y: 1000}, models.Waypoint{X: 1500, Y: 1000}},
+		{"corner", models.Position{X: 60, Y: 60}, models.Waypoint{X: 2500, Y: 1500}},
+		{"along the edge", models.Position{X: 100, Y: 300}, models.Waypoint{X: 300, Y: 1700}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waypoints, err := robot.planPath(test.start, test.goal)
+			if err != nil {
+				t.Fatalf("planPath: %v", err)
+			}
+			last := waypoints[len(waypoints)-1]
+			if last.X != test.goal.X || last.Y != test.goal.Y {
+				t.Errorf("last waypoint (%d,%d), want the goal (%d,%d)", last.X, last.Y, test.goal.X, test.goal.Y)
+			}
+			for _, point := range pathPoints(test.start, waypoints) {
+				if point.X < 0 || point.X > 3000 || point.Y < 0 || point.Y > 2000 {
+					t.Fatalf("the path leaves the field at (%d,%d)", point.X, point.Y)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanPathWallAttachedToBoundaryBand(t *testing.T) {
+
+	wall := []models.Point{{X: 0, Y: 900}, {X: 400, Y: 900}, {X: 400, Y: 1100}, {X: 0, Y: 1100}}
+	tests := []struct {
+		name  string
+		field models.Field
+	}{
+		{"obstacle", testField([]models.FieldObstacle{{Name: "wall", Polygon: wall}}, nil)},
+		{"forbidden zone", testField(nil, []models.FieldObstacle{{Name: "wall", Polygon: wall}})},
+	}
+
+	start := models.Position{X: 100, Y: 300}
+	goal := models.Waypoint{X: 300, Y: 1700}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			robot := testRobot(test.field)
+			waypoints, err := robot.planPath(start, goal)
+			if err != nil {
+				t.Fatalf("planPath: %v", err)
+			}
+			if len(waypoints) < 2 {
+				t.Fatalf("planPath returned %v, the straight line crosses the wall", waypoints)
+			}
+			for _, point := range pathPoints(start, waypoints) {
+				if pointInPolygon(float64(point.X), float64(point.Y), wall) {
+					t.Fatalf("the path %v crosses the wall at (%d,%d)", waypoints, point.X, point.Y)
+				}
+			}
+			if robot.pathBlocked(start, waypoints) {
+				t.Errorf("the planned path %v is reported as blocked", waypoints)
+			}
+			if !robot.pathBlocked(start, []models.Waypoint{goal}) {
+				t.Errorf("the straight line through the wall is not reported as blocked")
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package robot
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/utilities"
+	"github.com/brutella/can"
+	"github.com/fatih/color"
+)
+
+const (
+	RECORDER_DEFAULT_DIRECTORY = "logs"
+	RECORDER_DEFAULT_INTERFACE = "can0"
+	RECORDER_MAX_FILE_SIZE     = 10 * 1024 * 1024
+	RECORDER_MAX_FILES         = 10
+	RECORDER_FILE_PREFIX       = "candump-"
+	RECORDER_FILE_SUFFIX       = ".log"
+
+	canEffFlag = 0x80000000
+	canRtrFlag = 0x40000000
+	canEffMask = 0x1FFFFFFF
+	canSffMask = 0x000007FF
+)
+
+//RecorderStatus rappresents the state of the Recorder
+type RecorderStatus struct {
+	Enabled   bool   `json:"enabled"`
+	Directory string `json:"directory"`
+	File      string `json:"file"`
+	Frames    uint64 `json:"frames"`
+}
+
+//Recorder writes the frames exchanged by the Connection in candump log format,
+//rotating the file when it reaches MaxFileSize and keeping at most MaxFiles files.
+type Recorder struct {
+	Directory   string
+	Interface   string
+	MaxFileSize int64
+	MaxFiles    int
+
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+	frames uint64
+}
+
+//NewRecorder returns a disabled Recorder writing into the given directory.
+//The interface name is the one written in every log line (e.g. can0).
+func NewRecorder(directory string, networkInterface string) *Recorder {
+	return &Recorder{
+		Directory:   directory,
+		Interface:   networkInterface,
+		MaxFileSize: RECORDER_MAX_FILE_SIZE,
+		MaxFiles:    RECORDER_MAX_FILES,
+	}
+}
+
+//Configure changes the log directory and the interface name written in every log line.
+//The directory is used from the next log file, the interface name from the next recorded frame.
+func (rec *Recorder) Configure(directory string, networkInterface string) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	rec.Directory = directory
+	rec.Interface = networkInterface
+}
+
+//Start enables the recording on a new log file
+func (rec *Recorder) Start() error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.file != nil {
+		return nil
+	}
+	return rec.open()
+}
+
+//Stop disables the recording and closes the log file
+func (rec *Recorder) Stop() error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	return rec.close()
+}
+
+//Enabled returns true if the Recorder is writing frames
+func (rec *Recorder) Enabled() bool {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	return rec.file != nil
+}
+
+//Status returns the current state of the Recorder
+func (rec *Recorder) Status() RecorderStatus {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	status := RecorderStatus{
+		Enabled:   rec.file != nil,
+		Directory: rec.Directory,
+		Frames:    rec.frames,
+	}
+	if rec.file != nil {
+		status.File = rec.file.Name()
+	}
+	return status
+}
+
+//Record writes the frame in the log file if the recording is enabled
+func (rec *Recorder) Record(frm can.Frame) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.file == nil {
+		return
+	}
+
+	//every line is flushed so the log is complete even if the controller is killed
+	line := FormatCandumpLine(time.Now(), rec.Interface, frm)
+	n, err := rec.writer.WriteString(line)
+	if err == nil {
+		err = rec.writer.Flush()
+	}
+	if err != nil {
+		log.Printf("[%s] %s", utilities.CreateColorString("RECORDER", color.FgHiRed), err)
+		rec.close()
+		return
+	}
+	rec.size += int64(n)
+	rec.frames++
+
+	if rec.MaxFileSize > 0 && rec.size >= rec.MaxFileSize {
+		rec.close()
+		if err := rec.open(); err != nil {
+			log.Printf("[%s] %s", utilities.CreateColorString("RECORDER", color.FgHiRed), err)
+		}
+	}
+}
+
+//FormatCandumpLine returns the frame as a candump log line: (timestamp) interface ID#DATA
+func FormatCandumpLine(timestamp time.Time, networkInterface string, frm can.Frame) string {
+
+	var id string
+	if frm.ID&canEffFlag != 0 {
+		id = fmt.Sprintf("%08X", frm.ID&canEffMask)
+	} else {
+		id = fmt.Sprintf("%03X", frm.ID&canSffMask)
+	}
+
+	var data string
+	if frm.ID&canRtrFlag != 0 {
+		data = "R"
+	} else {
+		length := int(frm.Length)
+		if length > len(frm.Data) {
+			length = len(frm.Data)
+		}
+		data = fmt.Sprintf("%X", frm.Data[:length])
+	}
+
+	return fmt.Sprintf("(%d.%06d) %s %s#%s\n", timestamp.Unix(), timestamp.Nanosecond()/1000, networkInterface, id, data)
+}
+
+func (rec *Recorder) open() error {
+
+	if err := os.MkdirAll(rec.Directory, 0755); err != nil {
+		return err
+	}
+
+	name := filepath.Join(rec.Directory, RECORDER_FILE_PREFIX+time.Now().Format("2006-01-02_150405.000000")+RECORDER_FILE_SUFFIX)
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	rec.file = file
+	rec.writer = bufio.NewWriter(file)
+	rec.size = 0
+	log.Printf("[%s] %s", utilities.CreateColorString("RECORDER", color.FgGreen), "Recording CAN frames on "+name)
+
+	rec.removeOldFiles()
+	return nil
+}
+
+func (rec *Recorder) close() error {
+	if rec.file == nil {
+		return nil
+	}
+
+	err := rec.writer.Flush()
+	if closeErr := rec.file.Close(); err == nil {
+		err = closeErr
+	}
+	log.Printf("[%s] %s", utilities.CreateColorString("RECORDER", color.FgGreen), "Recording stopped on "+rec.file.Name())
+	rec.file = nil
+	rec.writer = nil
+	return err
+}
+
+func (rec *Recorder) removeOldFiles() {
+	if rec.MaxFiles <= 0 {
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(rec.Directory, RECORDER_FILE_PREFIX+"*"+RECORDER_FILE_SUFFIX))
+	if err != nil || len(files) <= rec.MaxFiles {
+		return
+	}
+
+	//file names contain the creation time, therefore the lexical order is the chronological one
+	sort.Strings(files)
+	for _, name := range files[:len(files)-rec.MaxFiles] {
+		if rec.file != nil && strings.HasSuffix(rec.file.Name(), filepath.Base(name)) {
+			continue
+		}
+		os.Remove(name)
+	}
+}
@@ -0,0 +1,294 @@
+package robot
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+//ReplayFrame rappresents a frame read from a candump log with its offset from the first frame
+type ReplayFrame struct {
+	Offset time.Duration
+	Frame  can.Frame
+}
+
+//ReplayStatus rappresents the progress of a ReplayTransport
+type ReplayStatus struct {
+	File     string  `json:"file"`
+	Speed    float64 `json:"speed"`
+	Stepped  bool    `json:"stepped"`
+	Frames   int     `json:"frames"`
+	Played   int     `json:"played"`
+	Offset   float64 `json:"offset"`
+	Duration float64 `json:"duration"`
+	Finished bool    `json:"finished"`
+}
+
+//ReplayTransport is a Transport playing the frames of a candump log.
+//The frames are emitted at the original timing scaled by Speed or, in stepped mode, only when Step is called.
+//The frames sent by the Robot are discarded.
+type ReplayTransport struct {
+	File    string
+	Speed   float64
+	Stepped bool
+
+	frames   []ReplayFrame
+	mutex    sync.Mutex
+	played   int
+	handlers []func(frm can.Frame)
+	counters transportCounters
+	steps    int
+	stepped  chan struct{}
+	closed   chan struct{}
+}
+
+//NewReplayTransport loads the given candump log.
+//A speed greater than 1 accelerates the replay, stepped replays wait for Step calls.
+func NewReplayTransport(file string, speed float64, stepped bool) (*ReplayTransport, error) {
+
+	if speed <= 0 {
+		return nil, errors.New("replay speed must be greater than 0")
+	}
+
+	frames, err := LoadCandumpLog(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("no frames in " + file)
+	}
+
+	return &ReplayTransport{
+		File:    file,
+		Speed:   speed,
+		Stepped: stepped,
+		frames:  frames,
+		stepped: make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}, nil
+}
+
+//LoadCandumpLog reads all the frames of a candump log file
+func LoadCandumpLog(file string) ([]ReplayFrame, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var frames []ReplayFrame
+	var first time.Time
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		timestamp, frm, err := ParseCandumpLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", file, line, err)
+		}
+		if len(frames) == 0 {
+			first = timestamp
+		}
+		frames = append(frames, ReplayFrame{Offset: timestamp.Sub(first), Frame: frm})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return frames, nil
+}
+
+//ParseCandumpLine parses a candump log line: (timestamp) interface ID#DATA
+func ParseCandumpLine(line string) (time.Time, can.Frame, error) {
+
+	var frm can.Frame
+
+	fields := strings.Fields(line)
+	if len(fields) != 3 || !strings.HasPrefix(fields[0], "(") || !strings.HasSuffix(fields[0], ")") {
+		return time.Time{}, frm, errors.New("invalid candump line")
+	}
+
+	timestamp := strings.Split(strings.Trim(fields[0], "()"), ".")
+	seconds, err := strconv.ParseInt(timestamp[0], 10, 64)
+	if err != nil {
+		return time.Time{}, frm, errors.New("invalid timestamp")
+	}
+	var micros int64
+	if len(timestamp) > 1 {
+		//the fraction is expressed in microseconds
+		fraction := (timestamp[1] + "000000")[:6]
+		if micros, err = strconv.ParseInt(fraction, 10, 64); err != nil {
+			return time.Time{}, frm, errors.New("invalid timestamp")
+		}
+	}
+
+	parts := strings.SplitN(fields[2], "#", 2)
+	if len(parts) != 2 {
+		return time.Time{}, frm, errors.New("invalid frame")
+	}
+
+	id, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return time.Time{}, frm, errors.New("invalid frame id")
+	}
+	frm.ID = uint32(id)
+	if len(parts[0]) > 3 {
+		frm.ID |= canEffFlag
+	}
+
+	if strings.HasPrefix(parts[1], "R") {
+		frm.ID |= canRtrFlag
+	} else {
+		data, err := hex.DecodeString(parts[1])
+		if err != nil || len(data) > len(frm.Data) {
+			return time.Time{}, frm, errors.New("invalid frame data")
+		}
+		frm.Length = uint8(len(data))
+		copy(frm.Data[:], data)
+	}
+
+	return time.Unix(seconds, micros*1000), frm, nil
+}
+
+//Name returns the backend name
+func (replay *ReplayTransport) Name() string {
+	return "replay:" + replay.File
+}
+
+//Send discards the frame, the replayed robot can not be commanded
+func (replay *ReplayTransport) Send(frm can.Frame) error {
+	replay.counters.sent(nil)
+	return nil
+}
+
+//Subscribe registers a function called for every replayed frame
+func (replay *ReplayTransport) Subscribe(handler func(frm can.Frame)) {
+	replay.mutex.Lock()
+	defer replay.mutex.Unlock()
+	replay.handlers = append(replay.handlers, handler)
+}
+
+//Connect plays the log until its end or until the transport is closed
+func (replay *ReplayTransport) Connect() error {
+
+	defer replay.counters.disconnected()
+
+	start := time.Now()
+	for i, frm := range replay.frames {
+		if replay.Stepped {
+			if !replay.waitStep() {
+				return nil
+			}
+		} else {
+			wait := time.Until(start.Add(time.Duration(float64(frm.Offset) / replay.Speed)))
+			if wait > 0 {
+				select {
+				case <-replay.closed:
+					return nil
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		replay.mutex.Lock()
+		replay.played = i + 1
+		handlers := replay.handlers
+		replay.mutex.Unlock()
+
+		replay.counters.received()
+		for _, handler := range handlers {
+			handler(frm.Frame)
+		}
+	}
+
+	printInfo("Replay of " + replay.File + " finished")
+	<-replay.closed
+	return nil
+}
+
+//waitStep consumes a step, it returns false if the transport is closed
+func (replay *ReplayTransport) waitStep() bool {
+	for {
+		replay.mutex.Lock()
+		if replay.steps > 0 {
+			replay.steps--
+			replay.mutex.Unlock()
+			return true
+		}
+		replay.mutex.Unlock()
+
+		select {
+		case <-replay.closed:
+			return false
+		case <-replay.stepped:
+		}
+	}
+}
+
+//Step plays the next frames of a stepped replay
+func (replay *ReplayTransport) Step(frames int) error {
+	if !replay.Stepped {
+		return errors.New("the replay is not stepped")
+	}
+	if frames <= 0 {
+		return errors.New("the number of frames must be greater than 0")
+	}
+
+	replay.mutex.Lock()
+	replay.steps += frames
+	replay.mutex.Unlock()
+
+	select {
+	case replay.stepped <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+//Close stops the replay
+func (replay *ReplayTransport) Close() error {
+	replay.mutex.Lock()
+	defer replay.mutex.Unlock()
+	select {
+	case <-replay.closed:
+	default:
+		close(replay.closed)
+	}
+	return nil
+}
+
+//Stats returns the frame counters of the replay
+func (replay *ReplayTransport) Stats() TransportStats {
+	return replay.counters.snapshot(replay.Name())
+}
+
+//Status returns the progress of the replay
+func (replay *ReplayTransport) Status() ReplayStatus {
+	replay.mutex.Lock()
+	defer replay.mutex.Unlock()
+
+	status := ReplayStatus{
+		File:     replay.File,
+		Speed:    replay.Speed,
+		Stepped:  replay.Stepped,
+		Frames:   len(replay.frames),
+		Played:   replay.played,
+		Duration: replay.frames[len(replay.frames)-1].Offset.Seconds(),
+		Finished: replay.played == len(replay.frames),
+	}
+	if replay.played > 0 {
+		status.Offset = replay.frames[replay.played-1].Offset.Seconds()
+	}
+	return status
+}
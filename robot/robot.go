@@ -1,13 +1,17 @@
 package robot
 
 import (
-	"bytes"
-	"encoding/binary"
+	"errors"
 	"log"
+	"math"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/arslab/robot_controller/codec"
+	"github.com/arslab/robot_controller/dbc"
 	"github.com/arslab/robot_controller/models"
 	"github.com/arslab/robot_controller/utilities"
 	"github.com/brutella/can"
@@ -26,36 +30,83 @@ const (
 	ID_OBST_MAP             = 0x70f
 )
 
+//BATTERY_MESSAGE is the DBC message of the battery frame. It is sent by the power board,
+//whose identifier is not defined by the firmware sources, so the frame is matched by name
+//and its identifier is the one of the DBC (ID_BATTERY in the built-in description)
+const (
+	BATTERY_MESSAGE = "BATTERY"
+	ID_BATTERY      = 0x404
+)
+
 //Robot rappresents the logical Robot
 type Robot struct {
-	Connection             Connection
-	StartPositionSetted    bool
-	StartPosition          models.Position
-	Position               models.Position
-	Speed                  int16
-	Stopped                bool
-	CallbackPositionUpdate func(pos models.Position)
+	Connection             *Connection
+	Obstacles              *ObstacleMap
+	Path                   *PathExecutor
+	Planner                *Planner
+	Match                  *MatchClock
 	Type                   string
-	Color                  uint8
-	StarterEnabled         bool
-	TimerBattery           int16
+	mutex                  sync.RWMutex
+	state                  models.RobotState
+	callbackPositionUpdate func(pos models.Position)
+	callbackStatusChange   func(previous models.RobotStatus, current models.RobotStatus)
+	callbackGoalUpdate     func(goal models.MotionGoal)
+	goalUpdatesMutex       sync.Mutex
+	goalUpdates            []models.MotionGoal
+	goalUpdatesRunning     bool
+	currentHandle          *MotionHandle
+	handles                []*MotionHandle
+	lastHandleID           uint64
+	signalsMutex           sync.RWMutex
+	database               *dbc.Database
+	signals                map[string]DecodedMessage
+	field                  models.Field
+	batteryConfig          models.BatteryConfig
+	batteryLow             bool
+	callbackBatteryLow     func(battery models.Battery)
 }
 
-//NewRobot return a new Robot instance
-func NewRobot(networkInterface string) (*Robot, error) {
+//NewRobot return a new Robot instance communicating through the given Transport
+func NewRobot(transport Transport) (*Robot, error) {
+
+	database, err := dbc.Parse(strings.NewReader(DEFAULT_DBC))
+	if err != nil {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
+	}
+
+	field, err := ParseField([]byte(DEFAULT_FIELD))
+	if err != nil {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
+	}
 
-	conn := NewConnection(networkInterface)
+	batteryConfig, err := ParseBatteryConfig([]byte(DEFAULT_BATTERY))
+	if err != nil {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
+	}
 
 	robot := Robot{
-		Connection:          *conn,
-		StartPositionSetted: false,
-		Position:            models.Position{X: 0, Y: 0, Angle: 0},
-		StartPosition:       models.Position{X: -1000, Y: -1000, Angle: -1000},
-		Speed:               0,
-		Stopped:             false,
-		Type:                os.Getenv("ROBOT"),
-		TimerBattery:        25 * 60,
+		Connection: NewConnection(transport),
+		Obstacles:  NewObstacleMap(OBSTACLE_EXPIRY),
+		Type:       os.Getenv("ROBOT"),
+		state: models.RobotState{
+			Timestamp:           time.Now(),
+			StartPositionSetted: false,
+			Position:            models.Position{X: 0, Y: 0, Angle: 0},
+			Speed:               0,
+			Status:              models.RobotStatus{State: models.STATE_UNKNOWN},
+			TimerBattery:        batteryConfig.TimerDuration,
+		},
+		database:      database,
+		signals:       make(map[string]DecodedMessage),
+		field:         field,
+		batteryConfig: batteryConfig,
 	}
+	robot.Path = NewPathExecutor(&robot)
+	robot.Planner = NewPlanner(&robot)
+	robot.Match = NewMatchClock(&robot, MATCH_DURATION)
 
 	if connError := robot.Connection.Init(); connError != nil {
 		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), "Connection Error!")
@@ -69,10 +120,15 @@ func NewRobot(networkInterface string) (*Robot, error) {
 	}()
 
 	go func() {
-
-		for robot.TimerBattery > 0 {
+		//the timer is a fallback estimate of the battery charge, it is restarted by ResetBatteryTimer
+		for {
 			time.Sleep(time.Second)
-			robot.TimerBattery--
+			if robot.GetBatteryTimer() > 0 {
+				robot.updateState(func(state *models.RobotState) {
+					state.TimerBattery--
+				})
+			}
+			robot.checkBattery()
 		}
 	}()
 
@@ -82,78 +138,107 @@ func NewRobot(networkInterface string) (*Robot, error) {
 }
 
 func (robot *Robot) onDataReceived(frm can.Frame) {
-	data := frm.Data[:]
+
+	name, signals, ok := robot.decodeFrame(frm)
+	if !ok {
+		return
+	}
+
+	if name == BATTERY_MESSAGE {
+		robot.updateState(func(state *models.RobotState) {
+			state.BatteryVoltage = signals["VOLTAGE"]
+			state.BatteryCurrent = signals["CURRENT"]
+			state.BatteryUpdate = time.Now()
+		})
+		if DEBUG_CAN {
+			log.Printf("%s : [V : %.3f, A : %.3f]\n", "Battery", signals["VOLTAGE"], signals["CURRENT"])
+		}
+		return
+	}
 
 	switch frm.ID {
 	case ID_ROBOT_POSITION:
 		//position
-
-		var posX int16
-		var posY int16
-		var angle int16
-
-		buf := bytes.NewBuffer(data[:2])
-		binary.Read(buf, binary.LittleEndian, &posX)
-		buf = bytes.NewBuffer(data[2:4])
-		binary.Read(buf, binary.LittleEndian, &posY)
-		buf = bytes.NewBuffer(data[4:6])
-		binary.Read(buf, binary.LittleEndian, &angle)
-		angle = angle / 100.0
-
-		if robot.StartPosition.X < -999 {
-			robot.StartPosition.X = posX
-			robot.StartPosition.Y = posY
-			robot.StartPosition.Angle = angle
+		position := models.Position{
+			X:     int16(signals["X"]),
+			Y:     int16(signals["Y"]),
+			Angle: int16(math.Round(signals["ANGLE"])),
 		}
 
-		robot.Position.X = posX
-		robot.Position.Y = posY
-		robot.Position.Angle = angle
+		robot.updateState(func(state *models.RobotState) {
+			if !state.StartPositionSetted {
+				state.StartPositionSetted = true
+				state.StartPosition = position
+			}
+			state.Position = position
+		})
+
+		robot.checkGoal()
+
+		robot.mutex.RLock()
+		callback := robot.callbackPositionUpdate
+		robot.mutex.RUnlock()
+		if callback != nil {
+			callback(position)
+		}
 		if DEBUG_CAN {
-			log.Printf("%s : [X : %d, Y : %d, A : %d]\n", "Position", posX, posY, angle)
+			log.Printf("%s : [X : %d, Y : %d, A : %d]\n", "Position", position.X, position.Y, position.Angle)
+		}
+	case ID_OTHER_ROBOT_POSITION:
+		position := models.Position{
+			X:     int16(signals["X"]),
+			Y:     int16(signals["Y"]),
+			Angle: int16(math.Round(signals["ANGLE"])),
+		}
+		robot.updateState(func(state *models.RobotState) {
+			state.OtherPosition = position
+			state.OtherPositionUpdate = time.Now()
+		})
+		if DEBUG_CAN {
+			log.Printf("%s : [X : %d, Y : %d, A : %d]\n", "Other Position", position.X, position.Y, position.Angle)
 		}
 	case ID_ROBOT_SPEED:
-		var speed int16
-		buf := bytes.NewBuffer(data[:4])
-		binary.Read(buf, binary.LittleEndian, &speed)
-		robot.Speed = speed
+		speed := int16(signals["SPEED"])
+		robot.updateState(func(state *models.RobotState) {
+			state.Speed = speed
+		})
 		if DEBUG_CAN {
 			log.Printf("%s : [%d]\n", "Linear Speed", speed)
 		}
 	case ID_ROBOT_STATUS:
-		var status int16
-		buf := bytes.NewBuffer(data[2:4])
-		binary.Read(buf, binary.LittleEndian, &status)
+		status := decodeStatus(signals)
+		robot.updateStatus(status)
+		robot.checkGoal()
 		if DEBUG_CAN {
-			log.Printf("%s : [%d]\n", "Status", status)
+			log.Printf("%s : [%d]\n", "Status", status.Word)
 		}
 	case ID_OBST_MAP:
-		var obstacle_number uint8
-		var valid uint8
-		var angleStart int16
-		var angleEnd int16
-		var distance int16
-
-		buf := bytes.NewBuffer(data[:1])
-		binary.Read(buf, binary.LittleEndian, &obstacle_number)
-		buf = bytes.NewBuffer(data[1:2])
-		binary.Read(buf, binary.LittleEndian, &valid)
-		buf = bytes.NewBuffer(data[2:4])
-		binary.Read(buf, binary.LittleEndian, &angleStart)
-		buf = bytes.NewBuffer(data[4:6])
-		binary.Read(buf, binary.LittleEndian, &angleEnd)
-		buf = bytes.NewBuffer(data[6:8])
-		binary.Read(buf, binary.LittleEndian, &distance)
+		frame := models.ObstacleFrame{
+			Number:     uint8(signals["OBSTACLE_NUMBER"]),
+			Valid:      uint8(signals["VALID"]),
+			AngleStart: int16(signals["ANGLE_START"]),
+			AngleEnd:   int16(signals["ANGLE_END"]),
+			Distance:   int16(signals["DISTANCE"]),
+		}
+		robot.Obstacles.Update(frame, robot.GetPosition())
 		if DEBUG_CAN {
-			log.Printf("%s : Number: [%d], Valid: [%d], AStart: [%d], AEnd: [%d], Distance: [%d]\n", "Obstacle map", obstacle_number, valid, angleStart, angleEnd, distance)
+			log.Printf("%s : Number: [%d], Valid: [%d], AStart: [%d], AEnd: [%d], Distance: [%d]\n", "Obstacle map", frame.Number, frame.Valid, frame.AngleStart, frame.AngleEnd, frame.Distance)
 		}
-
-		//default:
-		//	log.Printf("%s : [%x]\n", "UNKNOWN ID CAN", frm.ID)
 	}
 
 }
 
+//updateState applies the change to the state, incrementing its sequence number
+func (robot *Robot) updateState(change func(state *models.RobotState)) models.RobotState {
+	robot.mutex.Lock()
+	defer robot.mutex.Unlock()
+
+	change(&robot.state)
+	robot.state.Sequence++
+	robot.state.Timestamp = time.Now()
+	return robot.state
+}
+
 func printError(s string) {
 	log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), s)
 }
@@ -162,91 +247,154 @@ func printInfo(s string) {
 	log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiCyan), s)
 }
 
-//SetCallbackUpadetePosition set the callback position update function
-func (robot *Robot) SetCallbackUpadetePosition(cb func(position models.Position)) {
-	robot.CallbackPositionUpdate = cb
+//sendMotionCommand encodes and sends the command on ID_MOTION_CMD.
+//After the end of the match only the commands that do not move the robot are sent.
+func (robot *Robot) sendMotionCommand(cmd models.MotionCommand) error {
+	switch cmd.CMD {
+	case models.MC_FW_TO_DISTANCE, models.MC_FW_TO_POINT, models.MC_ROTATE_RELATIVE:
+		if robot.Match.Finished() {
+			return ErrMatchFinished
+		}
+	}
+	data, err := codec.EncodeMotionCommand(cmd)
+	if err != nil {
+		return err
+	}
+	return robot.Connection.SendFrame(ID_MOTION_CMD, data)
 }
 
-//GetPosition returns the position of the Robot
-func (robot *Robot) GetPosition() models.Position {
+//sendStrategyCommand encodes and sends the command on ID_ST_CMD, with the elapsed match time (seconds)
+func (robot *Robot) sendStrategyCommand(cmd models.StrategyCommand) error {
+	cmd.ELAPSED_TIME = int16(robot.Match.Elapsed().Seconds())
+	data, err := codec.EncodeStrategyCommand(cmd)
+	if err != nil {
+		return err
+	}
+	return robot.Connection.SendFrame(ID_ST_CMD, data)
+}
 
-	return robot.Position
+//SetCallbackUpadetePosition set the callback position update function
+func (robot *Robot) SetCallbackUpadetePosition(cb func(position models.Position)) {
+	robot.mutex.Lock()
+	defer robot.mutex.Unlock()
+	robot.callbackPositionUpdate = cb
 }
 
-// //UpdatePosition logical position from I2C board
-// func (robot *Robot) UpdatePosition() error {
+//SetCallbackStatusChange set the function called when the robot state changes
+func (robot *Robot) SetCallbackStatusChange(cb func(previous models.RobotStatus, current models.RobotStatus)) {
+	robot.mutex.Lock()
+	defer robot.mutex.Unlock()
+	robot.callbackStatusChange = cb
+}
 
-// 	read := make([]byte, 6)
-// 	if err := robot.Connection.ReceiveData(read, REG_GET_POSITION); err != nil {
-// 		printError("UpdatePosition Error!")
-// 		return err
-// 	}
-// 	//numBytes := []byte{read[0], read[1]}
-// 	var x int16
-// 	buf := bytes.NewBuffer(read[:2])
-// 	binary.Read(buf, binary.LittleEndian, &x)
+//decodeStatus returns the status described by the signals of the ROBOT_STATUS message,
+//the position of the flags in the status word is given by the DBC
+func decodeStatus(signals map[string]float64) models.RobotStatus {
+
+	status := models.RobotStatus{
+		Word:           uint16(signals["STATUS"]),
+		Moving:         signals["MOVING"] != 0,
+		TargetReached:  signals["TARGET_REACHED"] != 0,
+		Blocked:        signals["BLOCKED"] != 0,
+		Error:          signals["ERROR"] != 0,
+		ErrorCode:      uint8(signals["ERROR_CODE"]),
+		StarterEnabled: signals["STARTER_ENABLED"] != 0,
+		StarterPulled:  signals["STARTER_PULLED"] != 0,
+	}
+	status.State = models.StatusState(status)
+	return status
+}
 
-// 	var y int16
-// 	buf = bytes.NewBuffer(read[2:4])
-// 	binary.Read(buf, binary.LittleEndian, &y)
+//updateStatus stores the decoded status and notifies the state changes
+func (robot *Robot) updateStatus(current models.RobotStatus) {
 
-// 	var a int16
-// 	buf = bytes.NewBuffer(read[4:6])
-// 	binary.Read(buf, binary.LittleEndian, &a)
-// 	a = a / 100.0
+	robot.mutex.Lock()
+	previous := robot.state.Status
+	unchanged := previous
+	unchanged.Since = current.Since
+	if unchanged == current && previous.State != models.STATE_UNKNOWN {
+		robot.mutex.Unlock()
+		return
+	}
 
-// 	if !robot.StartPositionSetted {
-// 		robot.StartPositionSetted = true
+	if current.State == previous.State {
+		current.Since = previous.Since
+	} else {
+		current.Since = time.Now()
+	}
+	robot.state.Status = current
+	robot.state.Sequence++
+	robot.state.Timestamp = time.Now()
+	callback := robot.callbackStatusChange
+	//the match starts when the armed starter is pulled
+	starterPulled := previous.State != models.STATE_UNKNOWN && !previous.StarterPulled && current.StarterPulled &&
+		(robot.state.StarterEnabled || current.StarterEnabled)
+	robot.mutex.Unlock()
+
+	if current.State != previous.State {
+		printInfo("Status changed: " + previous.State + " -> " + current.State)
+	}
+	if starterPulled {
+		if err := robot.Match.Start(); err != nil {
+			printError("Starter pulled: " + err.Error())
+		}
+	}
+	if callback != nil {
+		callback(previous, current)
+	}
+}
 
-// 		robot.StartPosition = models.Position{
-// 			X:     x,
-// 			Y:     y,
-// 			Angle: a,
-// 		}
-// 		printInfo("Start Position: X: " + strconv.Itoa(int(robot.StartPosition.X)) + ", Y: " + strconv.Itoa(int(robot.StartPosition.Y)) + ", Angle: " + strconv.Itoa(int(robot.StartPosition.Angle)))
+//Snapshot returns a consistent copy of the whole robot state
+func (robot *Robot) Snapshot() models.RobotState {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
 
-// 	}
+	return robot.state
+}
 
-// 	// robot.Position.X = (x - robot.LastBoardPosition.X) + robot.Position.X
-// 	// robot.Position.Y = (y - robot.LastBoardPosition.Y) + robot.Position.Y
-// 	// robot.Position.Angle = (a - robot.LastBoardPosition.Angle) + robot.Position.Angle
+//GetStatus returns the last status of the Robot
+func (robot *Robot) GetStatus() models.RobotStatus {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
 
-// 	deltaX := (x - robot.StartPosition.X)
-// 	deltaY := (y - robot.StartPosition.Y)
+	return robot.state.Status
+}
 
-// 	radiands := float64(robot.StartPosition.Angle) * (math.Pi / 180)
+//GetPosition returns the position of the Robot
+func (robot *Robot) GetPosition() models.Position {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
 
-// 	robot.LastBoardPosition.X = x
-// 	robot.LastBoardPosition.Y = y
-// 	robot.LastBoardPosition.Angle = a
+	return robot.state.Position
+}
 
-// 	//println("dX", deltaX, "dY", deltaY)
+//GetOtherPosition returns the position of the opponent robot, false if it was never received
+func (robot *Robot) GetOtherPosition() (models.Position, bool) {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
 
-// 	newX := int16(float64(deltaX)*math.Cos(radiands) + float64(deltaY)*math.Sin(radiands))
-// 	newY := int16(float64(-deltaX)*math.Sin(radiands) + float64(deltaY)*math.Cos(radiands))
-// 	newA := a - robot.StartPosition.Angle
+	return robot.state.OtherPosition, !robot.state.OtherPositionUpdate.IsZero()
+}
 
-// 	if newA < -180 {
-// 		newA = 360 + newA
-// 	} else if newA > 180 {
-// 		newA = 360 - newA
-// 	}
+//GetSpeed returns the linear speed of the Robot
+func (robot *Robot) GetSpeed() int16 {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
 
-// 	robot.Position.X = newX
-// 	robot.Position.Y = newY
-// 	robot.Position.Angle = newA
+	return robot.state.Speed
+}
 
-// 	//println("LogicX:", robot.Position.X, "LogicY:", robot.Position.Y, "LogicAngle:", robot.Position.Angle)
+//GetBatteryTimer returns the remaining seconds of the battery timer
+func (robot *Robot) GetBatteryTimer() int16 {
+	robot.mutex.RLock()
+	defer robot.mutex.RUnlock()
 
-// 	//printInfo("Position updated")
-// 	if robot.CallbackPositionUpdate != nil {
-// 		robot.CallbackPositionUpdate(robot.Position)
-// 	}
-// 	return nil
-// }
+	return robot.state.TimerBattery
+}
 
-//SetPosition set the position on the can bus
-func (robot *Robot) SetPosition(p models.Position) error {
+//SetPosition set the position on the can bus.
+//The returned handle is resolved when the robot reports the new position.
+func (robot *Robot) SetPosition(p models.Position) (*MotionHandle, error) {
 
 	motionCMD := models.MotionCommand{
 		CMD:     models.MC_SET_POSITION,
@@ -255,14 +403,20 @@ func (robot *Robot) SetPosition(p models.Position) error {
 		PARAM_3: p.Angle,
 	}
 
-	err := robot.Connection.SendData(motionCMD, ID_MOTION_CMD)
+	if err := robot.CheckGeofence(p.X, p.Y); err != nil {
+		printError(err.Error())
+		return nil, err
+	}
+
+	err := robot.sendMotionCommand(motionCMD)
 
 	if err == nil {
+		handle := robot.startGoal(GOAL_CMD_SET_POSITION, p)
 		log.Printf("[%s] %s : X: %d, Y: %d, Angle: %d", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Position changed", p.X, p.Y, p.Angle)
-		return nil
+		return handle, nil
 	} else {
 		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
-		return err
+		return nil, err
 	}
 
 }
@@ -274,18 +428,19 @@ func (robot *Robot) SetSpeed(speed int16) error {
 		PARAM_1: speed,
 	}
 
-	err := robot.Connection.SendData(motionCMD, ID_MOTION_CMD)
+	err := robot.sendMotionCommand(motionCMD)
 
-	if err != nil {
+	if err == nil {
 		log.Printf("[%s] %s : Speed: %d", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Set Speed", speed)
 		return nil
 	} else {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
 		return err
 	}
 }
 
 //ForwardDistance move the robot about the given millimeters
-func (robot *Robot) ForwardDistance(distance int16) error {
+func (robot *Robot) ForwardDistance(distance int16) (*MotionHandle, error) {
 
 	// if robot.Stopped {
 	// 	printError("The robot id Stopped")
@@ -297,30 +452,65 @@ func (robot *Robot) ForwardDistance(distance int16) error {
 		PARAM_1: distance,
 	}
 
-	err := robot.Connection.SendData(motionCMD, ID_MOTION_CMD)
+	position := robot.GetPosition()
+	radians := float64(position.Angle) * math.Pi / 180
+	target := models.Position{
+		X:     clampInt16(float64(position.X) + float64(distance)*math.Cos(radians)),
+		Y:     clampInt16(float64(position.Y) + float64(distance)*math.Sin(radians)),
+		Angle: position.Angle,
+	}
 
-	if err != nil {
+	if err := robot.CheckGeofence(target.X, target.Y); err != nil {
+		printError(err.Error())
+		return nil, err
+	}
+
+	err := robot.sendMotionCommand(motionCMD)
+
+	if err == nil {
+		handle := robot.startGoal(GOAL_CMD_FORWARD_DISTANCE, target)
 		log.Printf("[%s] %s : Distance: %d", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Forward Distance", distance)
-		return nil
+		return handle, nil
 	} else {
-		return err
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
 	}
 }
 
-func (robot *Robot) StopMotors() error {
+func (robot *Robot) StopMotors() (*MotionHandle, error) {
 
 	motionCMD := models.MotionCommand{
 		CMD: models.MC_STOP,
 	}
 
-	err := robot.Connection.SendData(motionCMD, ID_MOTION_CMD)
+	err := robot.sendMotionCommand(motionCMD)
 
 	if err == nil {
+		handle := robot.startGoal(GOAL_CMD_STOP, robot.GetPosition())
 		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Motors Stopped")
-		return nil
+		return handle, nil
 	} else {
 		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
-		return err
+		return nil, err
+	}
+}
+
+//Brake stops the motors immediately (hard brake), while StopMotors decelerates with a controlled stop
+func (robot *Robot) Brake() (*MotionHandle, error) {
+
+	motionCMD := models.MotionCommand{
+		CMD: models.MC_BRAKE,
+	}
+
+	err := robot.sendMotionCommand(motionCMD)
+
+	if err == nil {
+		handle := robot.startGoal(GOAL_CMD_BRAKE, robot.GetPosition())
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Motors Braked")
+		return handle, nil
+	} else {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
 	}
 }
 
@@ -332,14 +522,16 @@ func (robot *Robot) Align(colorIn uint8) error {
 		cmd = models.ST_ALIGN_GRANDE
 	}
 
-	robot.Color = colorIn
+	robot.updateState(func(state *models.RobotState) {
+		state.Color = colorIn
+	})
 
 	motionCMD := models.StrategyCommand{
 		CMD:   cmd,
 		FLAGS: colorIn,
 	}
 
-	err := robot.Connection.SendData(motionCMD, ID_ST_CMD)
+	err := robot.sendStrategyCommand(motionCMD)
 
 	if err == nil {
 		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Aligning")
@@ -358,13 +550,15 @@ func (robot *Robot) ToggleStarter(enable bool) error {
 		cmd = models.ST_DISABLE_STARTER
 	}
 
-	robot.StarterEnabled = enable
+	robot.updateState(func(state *models.RobotState) {
+		state.StarterEnabled = enable
+	})
 
 	motionCMD := models.StrategyCommand{
 		CMD: cmd,
 	}
 
-	err := robot.Connection.SendData(motionCMD, ID_ST_CMD)
+	err := robot.sendStrategyCommand(motionCMD)
 
 	if err == nil {
 		log.Printf("[%s] %s %t", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Starter Toggled", enable)
@@ -386,74 +580,83 @@ func (robot *Robot) ResetBoard() error {
 	return nil
 }
 
-//ForwardToPoint move the robot to the defined point
-func (robot *Robot) ForwardToPoint(x int16, y int16) error {
+//ForwardToPoint move the robot to the defined point.
+//The returned handle is resolved using the position and status feedback.
+func (robot *Robot) ForwardToPoint(x int16, y int16) (*MotionHandle, error) {
 
-	// if robot.Stopped {
-	// 	printError("The robot id Stopped")
-	// 	return errors.New("The robot id Stopped")
-	// }
+	motionCMD := models.MotionCommand{
+		CMD:     models.MC_FW_TO_POINT,
+		PARAM_1: x,
+		PARAM_2: y,
+	}
 
-	// if err := robot.Connection.SendData(
-	// 	models.I2CMessage{
-	// 		Command: REG_FW_POINT,
-	// 		Val1:    x,
-	// 		Val2:    y,
-	// 		Val3:    0,
-	// 		End:     0,
-	// 	},
-	// 	0x60); err != nil {
-	// 	printError("ForwardToPoint Error!")
-	// 	return err
-	// }
-	// printInfo("Moved to X:" + strconv.Itoa(int(x)) + " Y:" + strconv.Itoa(int(y)))
-	return nil
+	if err := robot.CheckGeofence(x, y); err != nil {
+		printError(err.Error())
+		return nil, err
+	}
+
+	err := robot.sendMotionCommand(motionCMD)
+
+	if err == nil {
+		handle := robot.startGoal(GOAL_CMD_FORWARD_TO_POINT, models.Position{X: x, Y: y})
+		log.Printf("[%s] %s : X: %d, Y: %d", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Forward To Point", x, y)
+		return handle, nil
+	} else {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
+	}
 }
 
-//RelativeRotation rotate the robot about the given degrees
-func (robot *Robot) RelativeRotation(degree int16) error {
+//RelativeRotation rotate the robot about the given degrees (positive counterclockwise)
+func (robot *Robot) RelativeRotation(degree int16) (*MotionHandle, error) {
 
-	// if robot.Stopped {
-	// 	printError("The robot id Stopped")
-	// 	return errors.New("The robot id Stopped")
-	// }
+	motionCMD := models.MotionCommand{
+		CMD:     models.MC_ROTATE_RELATIVE,
+		PARAM_1: degree,
+	}
 
-	// if err := robot.Connection.SendData(
-	// 	models.I2CMessage{
-	// 		Command: REG_REL_ROTATION,
-	// 		Val1:    degree,
-	// 		Val2:    0,
-	// 		Val3:    0,
-	// 		End:     0,
-	// 	},
-	// 	0x60); err != nil {
-	// 	printError("RelativeRotation Error!")
-	// 	return err
-	// }
-	// printInfo("Relative Rotated by " + strconv.Itoa(int(degree)))
-	return nil
+	position := robot.GetPosition()
+	position.Angle = int16(normalizeAngle(float64(position.Angle) + float64(degree)))
+	err := robot.sendMotionCommand(motionCMD)
+
+	if err == nil {
+		handle := robot.startGoal(GOAL_CMD_RELATIVE_ROTATION, position)
+		log.Printf("[%s] %s : Degree: %d", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Relative Rotation", degree)
+		return handle, nil
+	} else {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
+	}
 }
 
-//AbsoluteRotation rotate the robot about the given degrees
-func (robot *Robot) AbsoluteRotation(degree int16) error {
+//AbsoluteRotation rotate the robot to the given heading, turning on the shortest side
+func (robot *Robot) AbsoluteRotation(degree int16) (*MotionHandle, error) {
 
-	// if robot.Stopped {
-	// 	printError("The robot id Stopped")
-	// 	return errors.New("The robot id Stopped")
-	// }
+	state := robot.Snapshot()
+	if !state.StartPositionSetted {
+		err := errors.New("heading unknown: no position received from the robot")
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
+	}
 
-	// if err := robot.Connection.SendData(
-	// 	models.I2CMessage{
-	// 		Command: REG_ABS_ROTATION,
-	// 		Val1:    degree,
-	// 		Val2:    0,
-	// 		Val3:    0,
-	// 		End:     0,
-	// 	},
-	// 	0x60); err != nil {
-	// 	printError("AbsoluteRotation Error!")
-	// 	return err
-	// }
+	target := int16(normalizeAngle(float64(degree)))
+	rotation := int16(angleDifference(target, state.Position.Angle))
 
-	return nil
+	motionCMD := models.MotionCommand{
+		CMD:     models.MC_ROTATE_RELATIVE,
+		PARAM_1: rotation,
+	}
+
+	position := state.Position
+	position.Angle = target
+	err := robot.sendMotionCommand(motionCMD)
+
+	if err == nil {
+		handle := robot.startGoal(GOAL_CMD_ABSOLUTE_ROTATION, position)
+		log.Printf("[%s] %s : Degree: %d, Rotation: %d", utilities.CreateColorString("ROBOT", color.FgHiCyan), "Absolute Rotation", target, rotation)
+		return handle, nil
+	} else {
+		log.Printf("[%s] %s", utilities.CreateColorString("ROBOT", color.FgHiRed), err)
+		return nil, err
+	}
 }
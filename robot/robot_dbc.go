@@ -0,0 +1,54 @@
+package robot
+
+//DEFAULT_DBC describes the frames of the motion controller firmware.
+//It is used when no DBC file is given to the robot (see Robot.LoadDBC).
+const DEFAULT_DBC = `VERSION ""
+
+BU_: CONTROLLER MOTION_CONTROL POWER
+
+BO_ 995 ROBOT_POSITION: 8 MOTION_CONTROL
+ SG_ X : 0|16@1- (1,0) [-32768|32767] "mm" CONTROLLER
+ SG_ Y : 16|16@1- (1,0) [-32768|32767] "mm" CONTROLLER
+ SG_ ANGLE : 32|16@1- (0.01,0) [-180|180] "deg" CONTROLLER
+
+BO_ 997 OTHER_ROBOT_POSITION: 8 MOTION_CONTROL
+ SG_ X : 0|16@1- (1,0) [-32768|32767] "mm" CONTROLLER
+ SG_ Y : 16|16@1- (1,0) [-32768|32767] "mm" CONTROLLER
+ SG_ ANGLE : 32|16@1- (0.01,0) [-180|180] "deg" CONTROLLER
+
+BO_ 996 ROBOT_SPEED: 8 MOTION_CONTROL
+ SG_ SPEED : 0|16@1- (1,0) [-32768|32767] "mm/s" CONTROLLER
+
+BO_ 1026 ROBOT_STATUS: 8 MOTION_CONTROL
+ SG_ STATUS : 16|16@1+ (1,0) [0|65535] "" CONTROLLER
+ SG_ MOVING : 16|1@1+ (1,0) [0|1] "" CONTROLLER
+ SG_ TARGET_REACHED : 17|1@1+ (1,0) [0|1] "" CONTROLLER
+ SG_ BLOCKED : 18|1@1+ (1,0) [0|1] "" CONTROLLER
+ SG_ ERROR : 19|1@1+ (1,0) [0|1] "" CONTROLLER
+ SG_ STARTER_ENABLED : 20|1@1+ (1,0) [0|1] "" CONTROLLER
+ SG_ STARTER_PULLED : 21|1@1+ (1,0) [0|1] "" CONTROLLER
+ SG_ ERROR_CODE : 24|8@1+ (1,0) [0|255] "" CONTROLLER
+
+BO_ 1028 BATTERY: 8 POWER
+ SG_ VOLTAGE : 0|16@1+ (0.001,0) [0|65.535] "V" CONTROLLER
+ SG_ CURRENT : 16|16@1- (0.001,0) [-32.768|32.767] "A" CONTROLLER
+
+BO_ 1807 OBST_MAP: 8 MOTION_CONTROL
+ SG_ OBSTACLE_NUMBER : 0|8@1+ (1,0) [0|255] "" CONTROLLER
+ SG_ VALID : 8|8@1+ (1,0) [0|255] "" CONTROLLER
+ SG_ ANGLE_START : 16|16@1- (1,0) [-32768|32767] "deg" CONTROLLER
+ SG_ ANGLE_END : 32|16@1- (1,0) [-32768|32767] "deg" CONTROLLER
+ SG_ DISTANCE : 48|16@1- (1,0) [-32768|32767] "mm" CONTROLLER
+
+BO_ 2032 MOTION_CMD: 8 CONTROLLER
+ SG_ CMD : 0|8@1+ (1,0) [0|255] "" MOTION_CONTROL
+ SG_ PARAM_1 : 8|16@1- (1,0) [-32768|32767] "" MOTION_CONTROL
+ SG_ PARAM_2 : 24|16@1- (1,0) [-32768|32767] "" MOTION_CONTROL
+ SG_ PARAM_3 : 40|16@1- (1,0) [-32768|32767] "" MOTION_CONTROL
+ SG_ FLAGS : 56|8@1- (1,0) [-128|127] "" MOTION_CONTROL
+
+BO_ 1808 ST_CMD: 8 CONTROLLER
+ SG_ CMD : 0|8@1+ (1,0) [0|255] "" MOTION_CONTROL
+ SG_ FLAGS : 8|8@1+ (1,0) [0|255] "" MOTION_CONTROL
+ SG_ ELAPSED_TIME : 16|16@1- (1,0) [-32768|32767] "" MOTION_CONTROL
+`
@@ -0,0 +1,88 @@
+package robot
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/arslab/robot_controller/dbc"
+	"github.com/brutella/can"
+)
+
+//DecodedMessage rappresents the last signal values received for a DBC message
+type DecodedMessage struct {
+	ID        uint32             `json:"id"`
+	Name      string             `json:"name"`
+	Signals   map[string]float64 `json:"signals"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+//LoadDBC replaces the frame descriptions with the ones of the given DBC file
+func (robot *Robot) LoadDBC(path string) error {
+
+	database, err := dbc.ParseFile(path)
+	if err != nil {
+		printError("DBC " + path + ": " + err.Error())
+		return err
+	}
+
+	robot.signalsMutex.Lock()
+	robot.database = database
+	robot.signals = make(map[string]DecodedMessage)
+	robot.signalsMutex.Unlock()
+
+	printInfo("Frame descriptions loaded from " + path)
+	return nil
+}
+
+//GetSignals returns the last decoded values of every received message, keyed by message name
+func (robot *Robot) GetSignals() map[string]DecodedMessage {
+	robot.signalsMutex.RLock()
+	defer robot.signalsMutex.RUnlock()
+
+	signals := make(map[string]DecodedMessage, len(robot.signals))
+	for name, message := range robot.signals {
+		signals[name] = message
+	}
+	return signals
+}
+
+//GetMessageSignals returns the last decoded values of the given message
+func (robot *Robot) GetMessageSignals(name string) (DecodedMessage, bool) {
+	robot.signalsMutex.RLock()
+	defer robot.signalsMutex.RUnlock()
+
+	for messageName, message := range robot.signals {
+		if strings.EqualFold(messageName, name) {
+			return message, true
+		}
+	}
+	return DecodedMessage{}, false
+}
+
+//decodeFrame decodes the frame using the DBC description and stores the signal values,
+//it returns the name of the message and its signals
+func (robot *Robot) decodeFrame(frm can.Frame) (string, map[string]float64, bool) {
+	robot.signalsMutex.Lock()
+	defer robot.signalsMutex.Unlock()
+
+	length := int(frm.Length)
+	if length > len(frm.Data) {
+		length = len(frm.Data)
+	}
+	message, values, err := robot.database.Decode(frm.ID, frm.Data[:length])
+	if errors.Is(err, dbc.ErrUnknownMessage) {
+		return "", nil, false
+	} else if err != nil {
+		printError(err.Error())
+		return "", nil, false
+	}
+
+	robot.signals[message.Name] = DecodedMessage{
+		ID:        frm.ID,
+		Name:      message.Name,
+		Signals:   values,
+		Timestamp: time.Now(),
+	}
+	return message.Name, values, true
+}
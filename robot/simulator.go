@@ -0,0 +1,423 @@
+package robot
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/codec"
+	"github.com/arslab/robot_controller/models"
+	"github.com/brutella/can"
+)
+
+const (
+	SIM_PERIOD          = 10 * time.Millisecond
+	SIM_TELEMETRY_EVERY = 10 //speed and status frames are sent every SIM_TELEMETRY_EVERY steps
+	SIM_DEFAULT_SPEED   = 500.0
+	SIM_LINEAR_ACCEL    = 1000.0
+	SIM_ANGULAR_SPEED   = 180.0
+	SIM_ANGULAR_ACCEL   = 720.0
+	SIM_SENSOR_RANGE    = 1000.0
+	SIM_OPPONENT_RADIUS = 150.0
+	SIM_BLOCK_DISTANCE  = 350.0
+	SIM_BATTERY_VOLTAGE = 16.6   //V at the start of the simulation
+	SIM_IDLE_CURRENT    = 0.8    //A
+	SIM_MOTION_CURRENT  = 4.0    //A at 1 m/s
+	SIM_BATTERY_DRAIN   = 0.0002 //V lost per ampere-second
+)
+
+//simulatorState rappresents the kinematic state of the simulated robot
+type simulatorState struct {
+	X             float64
+	Y             float64
+	Angle         float64
+	MaxSpeed      float64
+	Speed         float64
+	AngularSpeed  float64
+	Distance      float64 //remaining linear distance, signed
+	Rotation      float64 //remaining rotation in degrees, signed
+	Stopping      bool
+	TargetReached bool
+	Blocked       bool
+	Opponent      *models.Position
+	StarterArmed  bool
+	StarterPulled bool
+	Voltage       float64
+	Current       float64
+}
+
+//SimulatorTransport is a Transport simulating the robot motion controller.
+//It consumes the motion and strategy commands and emits position, speed and status frames.
+//If StarterDelay is not 0 the starter is pulled StarterDelay after it is enabled.
+type SimulatorTransport struct {
+	Period       time.Duration
+	StarterDelay time.Duration
+	mutex        sync.Mutex
+	state        simulatorState
+	handlers     []func(frm can.Frame)
+	counters     transportCounters
+	closed       chan struct{}
+}
+
+//NewSimulatorTransport returns a simulated robot placed in the origin
+func NewSimulatorTransport() *SimulatorTransport {
+	return &SimulatorTransport{
+		Period: SIM_PERIOD,
+		state: simulatorState{
+			MaxSpeed: SIM_DEFAULT_SPEED,
+			Voltage:  SIM_BATTERY_VOLTAGE,
+		},
+		closed: make(chan struct{}),
+	}
+}
+
+//SetOpponent places a still opponent robot on the field, nil removes it.
+//The simulator emits its position and, when it is within the sensor range, an obstacle map entry.
+func (sim *SimulatorTransport) SetOpponent(position *models.Position) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.state.Opponent = position
+}
+
+//Name returns the backend name
+func (sim *SimulatorTransport) Name() string {
+	return "simulator"
+}
+
+//Send delivers a frame to the simulated motion controller
+func (sim *SimulatorTransport) Send(frm can.Frame) error {
+
+	select {
+	case <-sim.closed:
+		err := errors.New("simulator closed")
+		sim.counters.sent(err)
+		return err
+	default:
+	}
+
+	sim.counters.sent(nil)
+
+	switch frm.ID {
+	case ID_MOTION_CMD:
+		sim.handleMotionCommand(frm.Data[:frm.Length])
+	case ID_ST_CMD:
+		sim.handleStrategyCommand(frm.Data[:frm.Length])
+	}
+	return nil
+}
+
+//PullStarter pulls the starter of the simulated robot, if it is enabled
+func (sim *SimulatorTransport) PullStarter() {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	if sim.state.StarterArmed {
+		sim.state.StarterPulled = true
+	}
+}
+
+//Subscribe registers a function called for every frame emitted by the simulator
+func (sim *SimulatorTransport) Subscribe(handler func(frm can.Frame)) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.handlers = append(sim.handlers, handler)
+}
+
+//Connect runs the simulation until the transport is closed
+func (sim *SimulatorTransport) Connect() error {
+
+	ticker := time.NewTicker(sim.Period)
+	defer ticker.Stop()
+
+	dt := sim.Period.Seconds()
+	for step := 0; ; step++ {
+		select {
+		case <-sim.closed:
+			sim.counters.disconnected()
+			return nil
+		case <-ticker.C:
+			sim.mutex.Lock()
+			sim.state.step(dt)
+			frames := []can.Frame{sim.state.positionFrame()}
+			if step%SIM_TELEMETRY_EVERY == 0 {
+				frames = append(frames, sim.state.speedFrame(), sim.state.statusFrame(), sim.state.batteryFrame())
+				frames = append(frames, sim.state.opponentFrames()...)
+			}
+			handlers := sim.handlers
+			sim.mutex.Unlock()
+
+			for _, frm := range frames {
+				sim.counters.received()
+				for _, handler := range handlers {
+					handler(frm)
+				}
+			}
+		}
+	}
+}
+
+//Close stops the simulation
+func (sim *SimulatorTransport) Close() error {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	select {
+	case <-sim.closed:
+	default:
+		close(sim.closed)
+	}
+	return nil
+}
+
+//Stats returns the frame counters of the simulator
+func (sim *SimulatorTransport) Stats() TransportStats {
+	return sim.counters.snapshot(sim.Name())
+}
+
+func (sim *SimulatorTransport) handleStrategyCommand(data []byte) {
+
+	cmd, err := codec.DecodeStrategyCommand(data)
+	if err != nil {
+		printError("Simulator: " + err.Error())
+		return
+	}
+
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+
+	switch cmd.CMD {
+	case models.ST_ENABLE_STARTER:
+		sim.state.StarterArmed = true
+		sim.state.StarterPulled = false
+		if sim.StarterDelay > 0 {
+			time.AfterFunc(sim.StarterDelay, sim.PullStarter)
+		}
+	case models.ST_DISABLE_STARTER:
+		sim.state.StarterArmed = false
+		sim.state.StarterPulled = false
+	}
+}
+
+func (sim *SimulatorTransport) handleMotionCommand(data []byte) {
+
+	cmd, err := codec.DecodeMotionCommand(data)
+	if err != nil {
+		printError("Simulator: " + err.Error())
+		return
+	}
+
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+
+	state := &sim.state
+	switch cmd.CMD {
+	case models.MC_SET_POSITION:
+		state.X = float64(cmd.PARAM_1)
+		state.Y = float64(cmd.PARAM_2)
+		state.Angle = float64(cmd.PARAM_3)
+	case models.MC_FW_TO_DISTANCE:
+		state.startMotion()
+		state.Rotation = 0
+		state.AngularSpeed = 0
+		state.Distance = float64(cmd.PARAM_1)
+	case models.MC_FW_TO_POINT:
+		state.startMotion()
+		state.Speed = 0
+		dx := float64(cmd.PARAM_1) - state.X
+		dy := float64(cmd.PARAM_2) - state.Y
+		state.Distance = math.Hypot(dx, dy)
+		if state.Distance >= 1 {
+			state.Rotation = normalizeAngle(math.Atan2(dy, dx)*180/math.Pi - state.Angle)
+		} else {
+			state.Distance = 0
+			state.TargetReached = true
+		}
+	case models.MC_ROTATE_RELATIVE:
+		state.startMotion()
+		state.Distance = 0
+		state.Speed = 0
+		state.Rotation = float64(cmd.PARAM_1)
+	case models.MC_SET_SPEED:
+		if cmd.PARAM_1 > 0 {
+			state.MaxSpeed = float64(cmd.PARAM_1)
+		}
+	case models.MC_STOP:
+		state.TargetReached = false
+		state.Stopping = true
+		state.Rotation = 0
+		state.AngularSpeed = 0
+	case models.MC_BRAKE:
+		state.TargetReached = false
+		state.Stopping = false
+		state.Distance = 0
+		state.Speed = 0
+		state.Rotation = 0
+		state.AngularSpeed = 0
+	}
+}
+
+//profileSpeed returns the new speed moving towards the target with a trapezoidal profile
+func profileSpeed(speed float64, remaining float64, maxSpeed float64, accel float64, dt float64) float64 {
+	target := math.Min(maxSpeed, math.Sqrt(2*accel*math.Abs(remaining)))
+	if remaining < 0 {
+		target = -target
+	}
+	if speed < target {
+		return math.Min(speed+accel*dt, target)
+	}
+	return math.Max(speed-accel*dt, target)
+}
+
+func (state *simulatorState) startMotion() {
+	state.Stopping = false
+	state.TargetReached = false
+	state.Blocked = false
+}
+
+//opponentAhead returns true if the opponent is closer than SIM_BLOCK_DISTANCE in the direction of travel
+func (state *simulatorState) opponentAhead() bool {
+	if state.Opponent == nil || state.Distance == 0 {
+		return false
+	}
+	dx := float64(state.Opponent.X) - state.X
+	dy := float64(state.Opponent.Y) - state.Y
+	if math.Hypot(dx, dy) > SIM_BLOCK_DISTANCE {
+		return false
+	}
+	heading := state.Angle
+	if state.Distance < 0 {
+		heading += 180
+	}
+	return math.Abs(normalizeAngle(math.Atan2(dy, dx)*180/math.Pi-heading)) < 45
+}
+
+func (state *simulatorState) step(dt float64) {
+
+	state.Current = SIM_IDLE_CURRENT + SIM_MOTION_CURRENT*math.Abs(state.Speed)/1000
+	state.Voltage = math.Max(0, state.Voltage-state.Current*dt*SIM_BATTERY_DRAIN)
+
+	//the rotation is completed before moving forward
+	if state.Rotation != 0 {
+		state.AngularSpeed = profileSpeed(state.AngularSpeed, state.Rotation, SIM_ANGULAR_SPEED, SIM_ANGULAR_ACCEL, dt)
+		da := state.AngularSpeed * dt
+		if math.Abs(da) >= math.Abs(state.Rotation) {
+			da = state.Rotation
+			state.AngularSpeed = 0
+			state.TargetReached = state.Distance == 0
+		}
+		state.Angle = normalizeAngle(state.Angle + da)
+		state.Rotation -= da
+		return
+	}
+
+	if state.opponentAhead() {
+		//emergency stop in front of the opponent
+		state.Speed = 0
+		state.Distance = 0
+		state.Blocked = true
+	}
+
+	if state.Stopping {
+		//controlled stop: decelerate and drop the remaining distance
+		state.Speed = profileSpeed(state.Speed, 0, state.MaxSpeed, SIM_LINEAR_ACCEL, dt)
+		if state.Speed == 0 {
+			state.Stopping = false
+			state.Distance = 0
+		}
+	} else if state.Distance != 0 {
+		state.Speed = profileSpeed(state.Speed, state.Distance, state.MaxSpeed, SIM_LINEAR_ACCEL, dt)
+	}
+
+	if state.Speed != 0 {
+		ds := state.Speed * dt
+		if !state.Stopping && math.Abs(ds) >= math.Abs(state.Distance) {
+			ds = state.Distance
+			state.Speed = 0
+			state.TargetReached = true
+		}
+		radians := state.Angle * math.Pi / 180
+		state.X += ds * math.Cos(radians)
+		state.Y += ds * math.Sin(radians)
+		state.Distance -= ds
+	}
+}
+
+func (state *simulatorState) moving() bool {
+	return state.Speed != 0 || state.AngularSpeed != 0 || state.Distance != 0 || state.Rotation != 0
+}
+
+func simulatorFrame(id uint32, data []byte) can.Frame {
+	frm := can.Frame{
+		ID:     id,
+		Length: uint8(len(data)),
+	}
+	copy(frm.Data[:], data)
+	return frm
+}
+
+func (state *simulatorState) positionFrame() can.Frame {
+	data, _ := codec.EncodePosition(models.Position{
+		X:     int16(math.Round(state.X)),
+		Y:     int16(math.Round(state.Y)),
+		Angle: int16(math.Round(state.Angle)),
+	})
+	return simulatorFrame(ID_ROBOT_POSITION, data)
+}
+
+func (state *simulatorState) speedFrame() can.Frame {
+	data, _ := codec.EncodeSpeed(int16(math.Round(state.Speed)))
+	return simulatorFrame(ID_ROBOT_SPEED, data)
+}
+
+func (state *simulatorState) statusFrame() can.Frame {
+	var status uint16
+	if state.moving() {
+		status |= models.STATUS_MOVING
+	}
+	if state.TargetReached {
+		status |= models.STATUS_TARGET_REACHED
+	}
+	if state.Blocked {
+		status |= models.STATUS_BLOCKED
+	}
+	if state.StarterArmed {
+		status |= models.STATUS_STARTER_ENABLED
+	}
+	if state.StarterPulled {
+		status |= models.STATUS_STARTER_PULLED
+	}
+	data, _ := codec.EncodeStatus(status)
+	return simulatorFrame(ID_ROBOT_STATUS, data)
+}
+
+func (state *simulatorState) batteryFrame() can.Frame {
+	data, _ := codec.EncodeBattery(state.Voltage, state.Current)
+	return simulatorFrame(ID_BATTERY, data)
+}
+
+func (state *simulatorState) opponentFrames() []can.Frame {
+	if state.Opponent == nil {
+		return nil
+	}
+
+	data, _ := codec.EncodePosition(*state.Opponent)
+	frames := []can.Frame{simulatorFrame(ID_OTHER_ROBOT_POSITION, data)}
+
+	dx := float64(state.Opponent.X) - state.X
+	dy := float64(state.Opponent.Y) - state.Y
+	distance := math.Hypot(dx, dy)
+
+	obstacle := models.ObstacleFrame{Number: 0}
+	if distance <= SIM_SENSOR_RANGE {
+		//the sector is relative to the robot heading
+		bearing := normalizeAngle(math.Atan2(dy, dx)*180/math.Pi - state.Angle)
+		halfWidth := math.Asin(math.Min(1, SIM_OPPONENT_RADIUS/math.Max(distance, SIM_OPPONENT_RADIUS))) * 180 / math.Pi
+		obstacle.Valid = 1
+		obstacle.AngleStart = int16(math.Round(normalizeAngle(bearing - halfWidth)))
+		obstacle.AngleEnd = int16(math.Round(normalizeAngle(bearing + halfWidth)))
+		obstacle.Distance = int16(math.Round(distance))
+	}
+	data, _ = codec.EncodeObstacle(obstacle)
+	frames = append(frames, simulatorFrame(ID_OBST_MAP, data))
+
+	return frames
+}
@@ -0,0 +1,68 @@
+package robot
+
+import (
+	"sync"
+
+	"github.com/brutella/can"
+)
+
+//Transport is the backend used by a Connection to exchange frames with the robot
+type Transport interface {
+	//Name returns a short description of the backend (e.g. the network interface)
+	Name() string
+	//Send writes a frame on the backend
+	Send(frm can.Frame) error
+	//Subscribe registers a function called for every frame received from the backend
+	Subscribe(handler func(frm can.Frame))
+	//Connect starts receiving frames and blocks until the backend is closed
+	Connect() error
+	//Close stops the backend
+	Close() error
+	//Stats returns the frame counters of the backend
+	Stats() TransportStats
+}
+
+//TransportStats rappresents the frame counters of a Transport
+type TransportStats struct {
+	Backend      string `json:"backend"`
+	FramesSent   uint64 `json:"frames_sent"`
+	FramesRecv   uint64 `json:"frames_received"`
+	SendErrors   uint64 `json:"send_errors"`
+	Disconnected bool   `json:"disconnected"`
+}
+
+//transportCounters is a goroutine safe TransportStats shared by the Transport implementations
+type transportCounters struct {
+	mutex sync.Mutex
+	stats TransportStats
+}
+
+func (c *transportCounters) sent(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err != nil {
+		c.stats.SendErrors++
+	} else {
+		c.stats.FramesSent++
+	}
+}
+
+func (c *transportCounters) received() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.FramesRecv++
+}
+
+func (c *transportCounters) disconnected() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Disconnected = true
+}
+
+func (c *transportCounters) snapshot(backend string) TransportStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.stats
+	stats.Backend = backend
+	return stats
+}
@@ -0,0 +1,63 @@
+package robot
+
+import (
+	"github.com/brutella/can"
+)
+
+//SocketCANTransport is the Transport backed by a SocketCAN network interface (e.g. can0)
+type SocketCANTransport struct {
+	Interface string
+	bus       *can.Bus
+	counters  transportCounters
+}
+
+//NewSocketCANTransport opens the given SocketCAN network interface
+func NewSocketCANTransport(networkInterface string) (*SocketCANTransport, error) {
+
+	bus, err := can.NewBusForInterfaceWithName(networkInterface)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SocketCANTransport{
+		Interface: networkInterface,
+		bus:       bus,
+	}, nil
+}
+
+//Name returns the network interface name
+func (t *SocketCANTransport) Name() string {
+	return "socketcan:" + t.Interface
+}
+
+//Send publishes the frame on the bus
+func (t *SocketCANTransport) Send(frm can.Frame) error {
+	err := t.bus.Publish(frm)
+	t.counters.sent(err)
+	return err
+}
+
+//Subscribe registers the handler on the bus
+func (t *SocketCANTransport) Subscribe(handler func(frm can.Frame)) {
+	t.bus.SubscribeFunc(func(frm can.Frame) {
+		t.counters.received()
+		handler(frm)
+	})
+}
+
+//Connect reads the frames from the bus and publishes them to the subscribers
+func (t *SocketCANTransport) Connect() error {
+	err := t.bus.ConnectAndPublish()
+	t.counters.disconnected()
+	return err
+}
+
+//Close disconnects the bus
+func (t *SocketCANTransport) Close() error {
+	return t.bus.Disconnect()
+}
+
+//Stats returns the frame counters of the bus
+func (t *SocketCANTransport) Stats() TransportStats {
+	return t.counters.snapshot(t.Name())
+}
@@ -0,0 +1,155 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/arslab/robot_controller/robot"
+	"github.com/gin-gonic/gin"
+)
+
+//ErrMissingPayload is returned when a command that requires data is sent without it
+var ErrMissingPayload = errors.New("missing payload")
+
+//commandRequest is a command received on the REST API or on the websocket
+type commandRequest struct {
+	params  map[string]string //query string and path parameters
+	data    []byte            //JSON payload
+	context context.Context   //done when the client is gone
+}
+
+//query returns the query string or path parameter
+func (request commandRequest) query(key string) string {
+	return request.params[key]
+}
+
+//hasData returns true if the request carries a payload
+func (request commandRequest) hasData() bool {
+	data := bytes.TrimSpace(request.data)
+	return len(data) > 0 && !bytes.Equal(data, []byte("null"))
+}
+
+//bind decodes the payload of the request into the payload type of the command
+func (request commandRequest) bind(payload interface{}) error {
+	if !request.hasData() {
+		return ErrMissingPayload
+	}
+	return json.Unmarshal(request.data, payload)
+}
+
+//commandReply is the status and the body of the reply to a command
+type commandReply struct {
+	status int
+	body   interface{}
+}
+
+//replyOK returns a 200 reply with the given body
+func replyOK(body interface{}) commandReply {
+	return commandReply{http.StatusOK, body}
+}
+
+//replyError returns a reply with the given status and the error message
+func replyError(status int, err error) commandReply {
+	return commandReply{status, gin.H{"error": err.Error()}}
+}
+
+//commandHandler executes a command, the same handler replies to the REST and websocket requests
+type commandHandler func(request commandRequest) commandReply
+
+//command is a robot command, reachable as REST operation and as websocket command
+type command struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	handler commandHandler
+}
+
+//commands maps the websocket command names to the robot commands, the REST routes are registered from the same table
+var commands = map[string]command{
+	"get_position":       {http.MethodGet, "/api/robot/position", getRobotPosition},
+	"set_position":       {http.MethodPost, "/api/robot/position", setRobotPosition},
+	"get_start_position": {http.MethodGet, "/api/robot/position/start", getStartPosition},
+	"set_start_position": {http.MethodPost, "/api/robot/position/start", setStartPosition},
+	"get_other_position": {http.MethodGet, "/api/robot/other/position", getOtherRobotPosition},
+
+	"get_color": {http.MethodGet, "/api/robot/color", getRobotColor},
+	"set_color": {http.MethodPost, "/api/robot/color", setRobotColor},
+
+	"get_state":     {http.MethodGet, "/api/robot/state", getRobotState},
+	"get_status":    {http.MethodGet, "/api/robot/status", getRobotStatus},
+	"get_obstacles": {http.MethodGet, "/api/robot/obstacles", getRobotObstacles},
+
+	"get_speed": {http.MethodGet, "/api/robot/speed", getRobotSpeed},
+	"set_speed": {http.MethodPost, "/api/robot/speed", setRobotSpeed},
+
+	"move_distance":   {http.MethodPost, "/api/robot/move/distance", robotForwardDistance},
+	"move_point":      {http.MethodPost, "/api/robot/move/point", robotForwardPoint},
+	"get_goal":        {http.MethodGet, "/api/robot/move/point", getRobotGoal},
+	"rotate_relative": {http.MethodPost, "/api/robot/rotate/relative", robotRelativeRotation},
+	"rotate_absolute": {http.MethodPost, "/api/robot/rotate/absolute", robotAbsoluteRotation},
+	"get_rotation":    {http.MethodGet, "/api/robot/rotate", getRobotGoal},
+
+	"get_commands": {http.MethodGet, "/api/robot/commands", getRobotCommands},
+	"get_command":  {http.MethodGet, "/api/robot/commands/:id", getRobotCommand},
+
+	"get_path":    {http.MethodGet, "/api/robot/path", getRobotPath},
+	"start_path":  {http.MethodPost, "/api/robot/path", startRobotPath},
+	"pause_path":  {http.MethodPost, "/api/robot/path/pause", controlRobotPath((*robot.PathExecutor).Pause)},
+	"resume_path": {http.MethodPost, "/api/robot/path/resume", controlRobotPath((*robot.PathExecutor).Resume)},
+	"cancel_path": {http.MethodPost, "/api/robot/path/cancel", controlRobotPath((*robot.PathExecutor).Cancel)},
+
+	"get_field":   {http.MethodGet, "/api/robot/field", getRobotField},
+	"get_plan":    {http.MethodGet, "/api/robot/plan", getRobotPlan},
+	"start_plan":  {http.MethodPost, "/api/robot/plan", startRobotPlan},
+	"cancel_plan": {http.MethodPost, "/api/robot/plan/cancel", cancelRobotPlan},
+
+	"get_match":   {http.MethodGet, "/api/robot/match", getMatch},
+	"start_match": {http.MethodPost, "/api/robot/match/start", startMatch},
+	"reset_match": {http.MethodPost, "/api/robot/match/reset", resetMatch},
+
+	"stop":    {http.MethodPost, "/api/robot/motors/stop", sendStop},
+	"brake":   {http.MethodPost, "/api/robot/motors/brake", sendBrake},
+	"align":   {http.MethodPost, "/api/robot/st/align", robotAlign},
+	"starter": {http.MethodPost, "/api/robot/st/starter", robotStarterToggle},
+
+	"get_battery":        {http.MethodGet, "/api/robot/battery", getRobotBattery},
+	"get_battery_status": {http.MethodGet, "/api/robot/battery/status", getRobotBatteryStatus},
+	"reset_battery":      {http.MethodPost, "/api/robot/battery/reset", resetRobotBattery},
+	"reset_board":        {http.MethodGet, "/api/robot/reset", resetRobotBoard},
+
+	"get_signals":         {http.MethodGet, "/api/robot/signals", getRobotSignals},
+	"get_message_signals": {http.MethodGet, "/api/robot/signals/:message", getRobotMessageSignals},
+
+	"get_connection": {http.MethodGet, "/api/robot/connection", getConnectionStats},
+	"get_recorder":   {http.MethodGet, "/api/robot/can/record", getCanRecorder},
+	"set_recorder":   {http.MethodPost, "/api/robot/can/record", toggleCanRecorder},
+	"get_replay":     {http.MethodGet, "/api/robot/replay", getReplayStatus},
+	"replay_step":    {http.MethodPost, "/api/robot/replay/step", replayStep},
+}
+
+//restCommand returns the gin handler of the command,
+//the query string and the path parameters are the parameters of the command
+func restCommand(handler commandHandler) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		data, err := ioutil.ReadAll(context.Request.Body)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		params := map[string]string{}
+		for key, values := range context.Request.URL.Query() {
+			params[key] = values[0]
+		}
+		for _, param := range context.Params {
+			params[param.Key] = param.Value
+		}
+
+		reply := handler(commandRequest{params: params, data: data, context: context.Request.Context()})
+		context.JSON(reply.status, reply.body)
+	}
+}
@@ -0,0 +1,462 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/robot"
+	"github.com/gin-gonic/gin"
+)
+
+//PUBLISHER_TICK is the period at which the publisher samples the robot state
+const PUBLISHER_TICK = 20 * time.Millisecond
+
+//PUBLISHER_LOG_BUFFER is the number of log lines waiting to be published, the others are dropped
+const PUBLISHER_LOG_BUFFER = 256
+
+//topics of the publisher
+const (
+	TOPIC_POSITION  = "position"
+	TOPIC_SPEED     = "speed"
+	TOPIC_STATUS    = "status"
+	TOPIC_OBSTACLES = "obstacles"
+	TOPIC_OPPONENT  = "opponent"
+	TOPIC_BATTERY   = "battery"
+	TOPIC_MATCH     = "match"
+	TOPIC_LOGS      = "logs"
+)
+
+//DEFAULT_TOPICS are subscribed by the clients that don't choose their topics
+var DEFAULT_TOPICS = []string{TOPIC_POSITION, TOPIC_OPPONENT, TOPIC_OBSTACLES, TOPIC_STATUS, TOPIC_MATCH, TOPIC_BATTERY}
+
+//ErrUnknownTopic is returned when subscribing to a topic that doesn't exist
+var ErrUnknownTopic = errors.New("unknown topic")
+
+//ErrUnknownTopicMode is returned when the delivery mode of a topic is not valid
+var ErrUnknownTopicMode = errors.New("unknown topic mode")
+
+//ErrUnknownClient is returned when the client is not connected to the publisher
+var ErrUnknownClient = errors.New("client not connected")
+
+//topic is a stream of messages, the sampled topics are read from the robot at every tick
+//and sent when they change, the event topics are sent when they happen
+type topic struct {
+	message  string
+	interval time.Duration
+	framed   bool
+	sample   func() (interface{}, bool)
+	initial  func() (interface{}, bool)
+}
+
+//topicSample is the last value of a sampled topic, by frame for the positions
+type topicSample struct {
+	sequence uint64
+	values   map[string]json.RawMessage
+	data     []byte
+}
+
+//subscription is the state of a topic subscribed by a client
+type subscription struct {
+	options  models.TopicOptions
+	sequence uint64
+	sent     time.Time
+}
+
+//publisherClient is a client connected to the publisher
+type publisherClient struct {
+	frame         string
+	events        bool
+	send          func(models.WebSocketMessage)
+	subscriptions map[string]*subscription
+}
+
+//delivery is a message waiting to be sent to a client
+type delivery struct {
+	send    func(models.WebSocketMessage)
+	message models.WebSocketMessage
+}
+
+//Publisher samples the robot state and sends the topics subscribed by every client
+type Publisher struct {
+	mutex   sync.Mutex
+	robot   *robot.Robot
+	topics  map[string]topic
+	samples map[string]topicSample
+	clients map[interface{}]*publisherClient
+	logs    chan string
+}
+
+//NewPublisher returns the publisher of the robot topics
+func NewPublisher(robot *robot.Robot) *Publisher {
+
+	publisher := &Publisher{
+		robot:   robot,
+		samples: map[string]topicSample{},
+		clients: map[interface{}]*publisherClient{},
+		logs:    make(chan string, PUBLISHER_LOG_BUFFER),
+	}
+	publisher.topics = map[string]topic{
+		TOPIC_POSITION: {message: "position", interval: PUBLISHER_TICK, framed: true, sample: func() (interface{}, bool) {
+			return robot.GetPosition(), true
+		}},
+		TOPIC_OPPONENT: {message: "other_position", interval: PUBLISHER_TICK, framed: true, sample: func() (interface{}, bool) {
+			return robot.GetOtherPosition()
+		}},
+		TOPIC_SPEED: {message: "speed", interval: 100 * time.Millisecond, sample: func() (interface{}, bool) {
+			return gin.H{"speed": robot.GetSpeed()}, true
+		}},
+		TOPIC_OBSTACLES: {message: "obstacles", interval: 100 * time.Millisecond, sample: func() (interface{}, bool) {
+			return robot.Obstacles.Obstacles(), true
+		}},
+		TOPIC_BATTERY: {message: "battery", interval: time.Second, sample: func() (interface{}, bool) {
+			return robot.GetBattery(), true
+		}},
+		TOPIC_MATCH: {message: "match", interval: time.Second, sample: func() (interface{}, bool) {
+			return robot.Match.Status(), true
+		}},
+		TOPIC_STATUS: {message: "status", initial: func() (interface{}, bool) {
+			status := robot.GetStatus()
+			return gin.H{"previous": status, "current": status}, true
+		}},
+		TOPIC_LOGS: {message: "log"},
+	}
+	return publisher
+}
+
+//Start starts the publisher loop
+func (publisher *Publisher) Start() {
+	go func() {
+		ticker := time.NewTicker(PUBLISHER_TICK)
+		for now := range ticker.C {
+			publisher.publishSamples(now)
+		}
+	}()
+	go func() {
+		for line := range publisher.logs {
+			publisher.Publish(TOPIC_LOGS, line)
+		}
+	}()
+}
+
+//Topics returns the names of the topics
+func (publisher *Publisher) Topics() []string {
+
+	names := make([]string, 0, len(publisher.topics))
+	for name := range publisher.topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Add connects a client, the messages are sent with its send function,
+//the positions are converted to its frame and the broadcast events are sent if events is set
+func (publisher *Publisher) Add(key interface{}, frame string, events bool, send func(models.WebSocketMessage)) {
+	publisher.mutex.Lock()
+	publisher.clients[key] = &publisherClient{frame: frame, events: events, send: send, subscriptions: map[string]*subscription{}}
+	publisher.mutex.Unlock()
+}
+
+//Remove disconnects a client
+func (publisher *Publisher) Remove(key interface{}) {
+	publisher.mutex.Lock()
+	delete(publisher.clients, key)
+	publisher.mutex.Unlock()
+}
+
+//Subscribe subscribes the client to the topics (or changes their options)
+//and returns all its subscriptions
+func (publisher *Publisher) Subscribe(key interface{}, topics map[string]models.TopicOptions) (map[string]models.TopicOptions, error) {
+
+	for name, options := range topics {
+		if _, ok := publisher.topics[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTopic, name)
+		}
+		if options.Mode != "" && options.Mode != models.TOPIC_MODE_CHANGE && options.Mode != models.TOPIC_MODE_INTERVAL {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTopicMode, options.Mode)
+		}
+	}
+
+	var initial []topic
+	publisher.mutex.Lock()
+	client, ok := publisher.clients[key]
+	if !ok {
+		publisher.mutex.Unlock()
+		return nil, ErrUnknownClient
+	}
+	for name, options := range topics {
+		topic := publisher.topics[name]
+		if options.Mode == "" {
+			options.Mode = models.TOPIC_MODE_CHANGE
+		}
+		if options.Interval <= 0 {
+			options.Interval = int(topic.interval / time.Millisecond)
+		}
+		if current, ok := client.subscriptions[name]; ok {
+			current.options = options
+			continue
+		}
+		client.subscriptions[name] = &subscription{options: options}
+		if topic.initial != nil {
+			initial = append(initial, topic)
+		}
+	}
+	subscriptions := client.options()
+	send := client.send
+	publisher.mutex.Unlock()
+
+	//the event topics send their current value, the sampled ones are sent at the next tick
+	for _, topic := range initial {
+		if value, ok := topic.initial(); ok {
+			if message, err := encodeMessage(topic.message, value); err == nil {
+				send(message)
+			}
+		}
+	}
+	return subscriptions, nil
+}
+
+//Unsubscribe removes the topics (all of them if none is given) from the client subscriptions
+//and returns the remaining ones
+func (publisher *Publisher) Unsubscribe(key interface{}, names []string) (map[string]models.TopicOptions, error) {
+
+	for _, name := range names {
+		if _, ok := publisher.topics[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTopic, name)
+		}
+	}
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	client, ok := publisher.clients[key]
+	if !ok {
+		return nil, ErrUnknownClient
+	}
+	if len(names) == 0 {
+		client.subscriptions = map[string]*subscription{}
+	}
+	for _, name := range names {
+		delete(client.subscriptions, name)
+	}
+	return client.options(), nil
+}
+
+//Subscriptions returns the topics subscribed by the client
+func (publisher *Publisher) Subscriptions(key interface{}) (map[string]models.TopicOptions, error) {
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	client, ok := publisher.clients[key]
+	if !ok {
+		return nil, ErrUnknownClient
+	}
+	return client.options(), nil
+}
+
+//Current returns the last message of the topic in the frame,
+//the current value for the event topics
+func (publisher *Publisher) Current(name string, frame string) (models.WebSocketMessage, bool) {
+
+	topic, ok := publisher.topics[name]
+	if !ok {
+		return models.WebSocketMessage{}, false
+	}
+	if topic.initial != nil {
+		value, ok := topic.initial()
+		if !ok {
+			return models.WebSocketMessage{}, false
+		}
+		message, err := encodeMessage(topic.message, value)
+		return message, err == nil
+	}
+	if !topic.framed {
+		frame = ""
+	}
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	value, ok := publisher.samples[name].values[frame]
+	return models.WebSocketMessage{Command: topic.message, Payload: value}, ok
+}
+
+//Publish sends the event to the clients subscribed to the topic
+func (publisher *Publisher) Publish(name string, payload interface{}) {
+
+	topic, ok := publisher.topics[name]
+	if !ok {
+		return
+	}
+	message, err := encodeMessage(topic.message, payload)
+	if err != nil {
+		return
+	}
+
+	var deliveries []delivery
+	publisher.mutex.Lock()
+	for _, client := range publisher.clients {
+		if _, ok := client.subscriptions[name]; ok {
+			deliveries = append(deliveries, delivery{client.send, message})
+		}
+	}
+	publisher.mutex.Unlock()
+
+	for _, delivery := range deliveries {
+		delivery.send(delivery.message)
+	}
+}
+
+//Broadcast sends the event to every client receiving the events, whatever its subscriptions
+func (publisher *Publisher) Broadcast(command string, payload interface{}) {
+
+	message, err := encodeMessage(command, payload)
+	if err != nil {
+		return
+	}
+
+	var deliveries []delivery
+	publisher.mutex.Lock()
+	for _, client := range publisher.clients {
+		if !client.events {
+			continue
+		}
+		deliveries = append(deliveries, delivery{client.send, message})
+	}
+	publisher.mutex.Unlock()
+
+	for _, delivery := range deliveries {
+		delivery.send(delivery.message)
+	}
+}
+
+//Write publishes the log lines on the logs topic, the lines are dropped when the clients are too slow
+func (publisher *Publisher) Write(data []byte) (int, error) {
+	select {
+	case publisher.logs <- strings.TrimRight(string(data), "\n"):
+	default:
+	}
+	return len(data), nil
+}
+
+//publishSamples samples the subscribed topics and sends them to the clients
+//the robot is read without holding the publisher lock, its callbacks publish events
+func (publisher *Publisher) publishSamples(now time.Time) {
+
+	publisher.mutex.Lock()
+	subscribed := map[string]bool{}
+	frames := map[string]bool{}
+	for _, client := range publisher.clients {
+		for name := range client.subscriptions {
+			subscribed[name] = true
+			if publisher.topics[name].framed {
+				frames[client.frame] = true
+			}
+		}
+	}
+	publisher.mutex.Unlock()
+
+	samples := map[string]topicSample{}
+	for name := range subscribed {
+		topic := publisher.topics[name]
+		if topic.sample == nil {
+			continue
+		}
+		value, ok := topic.sample()
+		if !ok {
+			continue
+		}
+		framed := map[string]interface{}{"": value}
+		if topic.framed {
+			framed = map[string]interface{}{}
+			for frame := range frames {
+				//the start-relative positions are not sent until the start pose is known
+				if position, err := publisher.robot.ToFrame(frame, value.(models.Position)); err == nil {
+					framed[frame] = position
+				}
+			}
+		}
+		//the values are encoded once for all the clients
+		values := map[string]json.RawMessage{}
+		for frame, value := range framed {
+			if data, err := json.Marshal(value); err == nil {
+				values[frame] = data
+			}
+		}
+		data, err := json.Marshal(values)
+		if err != nil {
+			continue
+		}
+		samples[name] = topicSample{values: values, data: data}
+	}
+
+	var deliveries []delivery
+	publisher.mutex.Lock()
+	for name, sample := range samples {
+		last, ok := publisher.samples[name]
+		if ok && bytes.Equal(last.data, sample.data) {
+			continue
+		}
+		sample.sequence = last.sequence + 1
+		publisher.samples[name] = sample
+	}
+	for _, client := range publisher.clients {
+		for name, subscription := range client.subscriptions {
+			topic := publisher.topics[name]
+			sample, ok := publisher.samples[name]
+			if topic.sample == nil || !ok {
+				continue
+			}
+			if now.Sub(subscription.sent) < time.Duration(subscription.options.Interval)*time.Millisecond {
+				continue
+			}
+			if subscription.options.Mode == models.TOPIC_MODE_CHANGE && subscription.sequence == sample.sequence {
+				continue
+			}
+			frame := ""
+			if topic.framed {
+				frame = client.frame
+			}
+			value, ok := sample.values[frame]
+			if !ok {
+				continue
+			}
+			subscription.sequence = sample.sequence
+			subscription.sent = now
+			deliveries = append(deliveries, delivery{client.send, models.WebSocketMessage{Command: topic.message, Payload: value}})
+		}
+	}
+	publisher.mutex.Unlock()
+
+	for _, delivery := range deliveries {
+		delivery.send(delivery.message)
+	}
+}
+
+//options returns the options of the subscriptions
+func (client *publisherClient) options() map[string]models.TopicOptions {
+
+	options := map[string]models.TopicOptions{}
+	for name, subscription := range client.subscriptions {
+		options[name] = subscription.options
+	}
+	return options
+}
+
+//encodeMessage returns the message with the JSON encoded payload
+func encodeMessage(command string, payload interface{}) (models.WebSocketMessage, error) {
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return models.WebSocketMessage{}, err
+	}
+	return models.WebSocketMessage{Command: command, Payload: data}, nil
+}
@@ -0,0 +1,215 @@
+package webserver
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/robot"
+	"github.com/arslab/robot_controller/utilities"
+	"github.com/fatih/color"
+	socketio "github.com/googollee/go-socket.io"
+)
+
+//SOCKETIO_ROOM is joined by every socket.io client, it receives the default topics and the events
+const SOCKETIO_ROOM = "robot_controller"
+
+//SOCKETIO_ROOM_BUFFER is the number of messages waiting to be sent to a room, the others are dropped
+const SOCKETIO_ROOM_BUFFER = 64
+
+//ErrUnknownRoom is returned when joining a room that is not a topic
+var ErrUnknownRoom = errors.New("unknown room")
+
+//handleSocketIO registers the socket.io events,
+//the telemetry is sent to the rooms of the topics (e.g. "position", "position:team", "logs")
+//and the commands are sent with the "command" event
+func (ws *WebServer) handleSocketIO() {
+
+	ws.ServerSocket.OnConnect("/", func(s socketio.Conn) error {
+		log.Printf("[%s] %s", utilities.CreateColorString("SOCKET IO", color.FgHiMagenta), "Client connected!")
+		s.SetContext("")
+		return ws.joinRoom(s, SOCKETIO_ROOM)
+	})
+
+	ws.ServerSocket.OnEvent("/", "command", func(s socketio.Conn, message models.WebSocketRequest) {
+		log.Printf("[%s] %s", utilities.CreateColorString("SOCKET IO", color.FgHiMagenta), "Client sent command: "+message.Command)
+
+		switch message.Command {
+		case WS_COMMAND_SUBSCRIBE, WS_COMMAND_UNSUBSCRIBE, WS_COMMAND_SUBSCRIPTIONS:
+			reply := ws.manageRooms(s, message)
+			s.Emit(reply.Command, reply)
+		default:
+			//the commands can wait for the motion so they don't block the connection
+			go func() {
+				reply := ws.ManageWebSocketMessages(s, message)
+				s.Emit(reply.Command, reply)
+			}()
+		}
+	})
+
+	ws.ServerSocket.OnError("/", func(s socketio.Conn, err error) {
+		log.Printf("[%s] %s", utilities.CreateColorString("SOCKET IO", color.FgHiRed), err)
+	})
+
+	//the handler is called while the connection is closing, after it has left its rooms,
+	//so it must not close it again (the close is not reentrant)
+	ws.ServerSocket.OnDisconnect("/", func(s socketio.Conn, reason string) {
+		ws.removeEmptyRooms()
+		log.Printf("[%s] %s", utilities.CreateColorString("SOCKET IO", color.FgHiMagenta), "Client disconnected!")
+	})
+}
+
+//manageRooms changes the rooms joined by the client, the topics are given as a list (["position", "logs"])
+//or with their options ({"position": {}}), which are ignored since the rooms share the default ones
+func (ws *WebServer) manageRooms(s socketio.Conn, msg models.WebSocketRequest) models.WebSocketResponse {
+
+	var rooms []string
+	if request := (commandRequest{data: msg.Data}); request.hasData() {
+		if err := request.bind(&rooms); err != nil {
+			topics := map[string]models.TopicOptions{}
+			if err = request.bind(&topics); err != nil {
+				return webSocketError(msg, http.StatusBadRequest, "invalid topics format")
+			}
+			rooms = nil
+			for room := range topics {
+				rooms = append(rooms, room)
+			}
+		}
+	}
+
+	switch msg.Command {
+	case WS_COMMAND_SUBSCRIBE:
+		for _, room := range rooms {
+			if err := ws.joinRoom(s, strings.TrimSpace(room)); err != nil {
+				return webSocketError(msg, http.StatusBadRequest, err.Error())
+			}
+		}
+	case WS_COMMAND_UNSUBSCRIBE:
+		if len(rooms) == 0 {
+			rooms = ws.joinedRooms(s)
+		}
+		for _, room := range rooms {
+			s.Leave(strings.TrimSpace(room))
+		}
+		ws.removeEmptyRooms()
+	}
+
+	return webSocketAck(msg, http.StatusOK, ws.joinedRooms(s))
+}
+
+//joinRoom adds the client to the room, the room is connected to the publisher when it is first joined
+func (ws *WebServer) joinRoom(s socketio.Conn, room string) error {
+
+	topics, frame, err := roomTopics(room)
+	if err != nil {
+		return err
+	}
+
+	ws.roomsMutex.Lock()
+	defer ws.roomsMutex.Unlock()
+
+	s.Join(room)
+	if _, ok := ws.rooms[room]; ok {
+		//the client receives the current value of the topics without waiting for the next change
+		go func() {
+			for _, topic := range topics {
+				if message, ok := ws.Publisher.Current(topic, frame); ok {
+					s.Emit(message.Command, message.Payload)
+				}
+			}
+		}()
+		return nil
+	}
+
+	//the messages are sent by the room goroutine so a slow client doesn't block the publisher,
+	//the queue is not closed since the publisher can still be sending when the room is removed
+	queue := make(chan models.WebSocketMessage, SOCKETIO_ROOM_BUFFER)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case message := <-queue:
+				ws.ServerSocket.BroadcastToRoom("/", room, message.Command, message.Payload)
+			case <-done:
+				return
+			}
+		}
+	}()
+	ws.Publisher.Add(roomKey(room), frame, room == SOCKETIO_ROOM, func(message models.WebSocketMessage) {
+		select {
+		case <-done:
+		case queue <- message:
+		default:
+		}
+	})
+	if _, err := ws.Publisher.Subscribe(roomKey(room), topicOptions(topics)); err != nil {
+		ws.Publisher.Remove(roomKey(room))
+		close(done)
+		s.Leave(room)
+		return err
+	}
+	ws.rooms[room] = done
+	return nil
+}
+
+//removeEmptyRooms disconnects the rooms without clients from the publisher,
+//they are connected again when a client joins them
+func (ws *WebServer) removeEmptyRooms() {
+
+	ws.roomsMutex.Lock()
+	defer ws.roomsMutex.Unlock()
+
+	for room, done := range ws.rooms {
+		if ws.ServerSocket.RoomLen("/", room) <= 0 {
+			ws.Publisher.Remove(roomKey(room))
+			close(done)
+			delete(ws.rooms, room)
+		}
+	}
+}
+
+//roomKey returns the publisher key of the room
+func roomKey(room string) string {
+	return "socket.io:" + room
+}
+
+//joinedRooms returns the topic rooms joined by the client
+func (ws *WebServer) joinedRooms(s socketio.Conn) []string {
+
+	ws.roomsMutex.Lock()
+	defer ws.roomsMutex.Unlock()
+
+	rooms := []string{}
+	for _, room := range s.Rooms() {
+		if _, ok := ws.rooms[room]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+//roomTopics returns the topics and the frame of the room,
+//the rooms are named by topic and optionally by frame ("position:team")
+func roomTopics(room string) ([]string, string, error) {
+
+	if room == SOCKETIO_ROOM {
+		return DEFAULT_TOPICS, "", nil
+	}
+
+	parts := strings.SplitN(room, ":", 2)
+	frame := ""
+	if len(parts) == 2 {
+		frame = parts[1]
+		if _, err := robotInstance.ToFrame(frame, models.Position{}); err == robot.ErrUnknownFrame {
+			return nil, "", err
+		}
+	}
+	if parts[0] == "" {
+		return nil, "", ErrUnknownRoom
+	}
+	return []string{parts[0]}, frame, nil
+}
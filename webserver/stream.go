@@ -0,0 +1,184 @@
+package webserver
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/robot"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+//STREAM_HISTORY is the number of events kept to resume the streams (Last-Event-ID)
+const STREAM_HISTORY = 512
+
+//STREAM_BUFFER is the number of events waiting to be sent to a client,
+//a slower client is disconnected and resumes from the history when it reconnects
+const STREAM_BUFFER = 128
+
+//STREAM_KEEPALIVE is the period of the comments sent when there are no events
+const STREAM_KEEPALIVE = 15 * time.Second
+
+//STREAM_TOPICS are the topics sent on the event stream
+var STREAM_TOPICS = []string{TOPIC_POSITION, TOPIC_SPEED, TOPIC_STATUS, TOPIC_OBSTACLES}
+
+//streamEvent is an event of the stream, identified by an increasing id
+type streamEvent struct {
+	id      uint64
+	message models.WebSocketMessage
+}
+
+//eventStream keeps the history of the events in a frame and sends them to the connected clients
+type eventStream struct {
+	mutex   sync.Mutex
+	lastID  uint64
+	history []streamEvent
+	clients map[chan streamEvent]bool
+}
+
+//publish adds the message to the history and sends it to the clients
+func (stream *eventStream) publish(message models.WebSocketMessage) {
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	stream.lastID++
+	event := streamEvent{id: stream.lastID, message: message}
+	stream.history = append(stream.history, event)
+	if len(stream.history) > STREAM_HISTORY {
+		stream.history = append([]streamEvent(nil), stream.history[len(stream.history)-STREAM_HISTORY:]...)
+	}
+
+	for client := range stream.clients {
+		select {
+		case client <- event:
+		default:
+			delete(stream.clients, client)
+			close(client)
+		}
+	}
+}
+
+//subscribe connects a client and returns the events after lastID that are still in the history,
+//the stream is not resumed if lastID was not sent by this stream (e.g. before a restart)
+//or if the events after it are no longer in the history
+func (stream *eventStream) subscribe(lastID uint64, resume bool) (chan streamEvent, []streamEvent, bool) {
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	var backlog []streamEvent
+	resume = resume && lastID <= stream.lastID
+	if len(stream.history) > 0 && lastID < stream.history[0].id-1 {
+		resume = false
+	}
+	if resume {
+		for _, event := range stream.history {
+			if event.id > lastID {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	client := make(chan streamEvent, STREAM_BUFFER)
+	stream.clients[client] = true
+	return client, backlog, resume
+}
+
+//unsubscribe disconnects a client
+func (stream *eventStream) unsubscribe(client chan streamEvent) {
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	if stream.clients[client] {
+		delete(stream.clients, client)
+		close(client)
+	}
+}
+
+//eventStream returns the event stream of the frame, it is connected to the publisher when it is first requested
+func (ws *WebServer) eventStream(frame string) (*eventStream, error) {
+
+	ws.streamsMutex.Lock()
+	defer ws.streamsMutex.Unlock()
+
+	if stream, ok := ws.streams[frame]; ok {
+		return stream, nil
+	}
+
+	stream := &eventStream{clients: map[chan streamEvent]bool{}}
+	key := "stream:" + frame
+	ws.Publisher.Add(key, frame, false, stream.publish)
+	if _, err := ws.Publisher.Subscribe(key, topicOptions(STREAM_TOPICS)); err != nil {
+		ws.Publisher.Remove(key)
+		return nil, err
+	}
+	ws.streams[frame] = stream
+	return stream, nil
+}
+
+//streamRobotEvents sends the position, speed, status and obstacle events as Server-Sent Events,
+//a client reconnecting with the Last-Event-ID header receives the events it missed
+func (ws *WebServer) streamRobotEvents(context *gin.Context) {
+
+	frame := context.Query("frame")
+	if _, err := robotInstance.ToFrame(frame, models.Position{}); err == robot.ErrUnknownFrame {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stream, err := ws.eventStream(frame)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	header := context.GetHeader("Last-Event-ID")
+	lastID, err := strconv.ParseUint(header, 10, 64)
+	resume := header != "" && err == nil
+	client, backlog, resume := stream.subscribe(lastID, resume)
+	defer stream.unsubscribe(client)
+
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	context.Header("Connection", "keep-alive")
+	context.Header("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+
+	//a new client, or one that missed events no longer in the history, receives the current values,
+	//without id since they are not in the history
+	if !resume {
+		for _, topic := range STREAM_TOPICS {
+			if message, ok := ws.Publisher.Current(topic, frame); ok {
+				context.Render(-1, sse.Event{Event: message.Command, Data: message.Payload})
+			}
+		}
+	}
+	for _, event := range backlog {
+		context.Render(-1, sse.Event{Id: strconv.FormatUint(event.id, 10), Event: event.message.Command, Data: event.message.Payload})
+	}
+
+	context.Writer.Flush()
+
+	keepalive := time.NewTicker(STREAM_KEEPALIVE)
+	defer keepalive.Stop()
+	context.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-client:
+			if !ok {
+				return false
+			}
+			context.Render(-1, sse.Event{Id: strconv.FormatUint(event.id, 10), Event: event.message.Command, Data: event.message.Payload})
+			return true
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ":keepalive\n\n")
+			return err == nil
+		case <-context.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -2,10 +2,14 @@ package webserver
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/arslab/robot_controller/models"
@@ -28,6 +32,11 @@ type WebServer struct {
 	Router        *gin.Engine
 	ServerSocket  *socketio.Server
 	ServerSocketM *melody.Melody
+	Publisher     *Publisher
+	roomsMutex    sync.Mutex
+	rooms         map[string]chan struct{}
+	streamsMutex  sync.Mutex
+	streams       map[string]*eventStream
 }
 
 var robotInstance *robot.Robot
@@ -35,7 +44,7 @@ var robotInstance *robot.Robot
 //NewWebServer returns a new WebServer
 func NewWebServer(robot *robot.Robot, address string, port int) *WebServer {
 	robotInstance = robot
-	serverSocket := newServerSocket()
+	serverSocket := socketio.NewServer(nil)
 	go func() {
 		err := serverSocket.Serve()
 
@@ -60,8 +69,33 @@ func NewWebServer(robot *robot.Robot, address string, port int) *WebServer {
 		Ssl:           false,
 		Router:        router,
 		ServerSocket:  serverSocket,
-		ServerSocketM: NewMelodyWebSocket(),
+		ServerSocketM: melody.New(),
+		Publisher:     NewPublisher(robot),
+		rooms:         map[string]chan struct{}{},
+		streams:       map[string]*eventStream{},
 	}
+	ws.Publisher.Start()
+	//the log lines are also published on the logs topic
+	log.SetOutput(io.MultiWriter(os.Stderr, ws.Publisher))
+
+	robot.SetCallbackStatusChange(func(previous models.RobotStatus, current models.RobotStatus) {
+		ws.Publisher.Publish(TOPIC_STATUS, gin.H{"previous": previous, "current": current})
+	})
+	robot.SetCallbackGoalUpdate(func(goal models.MotionGoal) {
+		ws.broadcastMessage("goal", goal)
+	})
+	robot.Path.SetCallbackUpdate(func(status models.PathStatus) {
+		ws.broadcastMessage("path", status)
+	})
+	robot.Planner.SetCallbackUpdate(func(status models.PlanStatus) {
+		ws.broadcastMessage("plan", status)
+	})
+	robot.Match.SetCallbackUpdate(func(status models.MatchStatus) {
+		ws.broadcastMessage("match", status)
+	})
+	robot.SetCallbackBatteryLow(func(battery models.Battery) {
+		ws.broadcastMessage("battery_warning", battery)
+	})
 
 	// Register REST
 	statikFS, err := fs.New()
@@ -72,25 +106,12 @@ func NewWebServer(robot *robot.Robot, address string, port int) *WebServer {
 	staticGroup := router.Group("/controller")
 	staticGroup.StaticFS("/", statikFS)
 
+	//the robot commands are registered from the table shared with the websocket commands
 	apiGroup := router.Group("/api")
-	apiGroup.GET("/robot/position", func(context *gin.Context) { getRobotPosition(context) })
-	apiGroup.POST("/robot/position", func(context *gin.Context) { setRobotPosition(context) })
-
-	apiGroup.GET("/robot/speed", func(context *gin.Context) { getRobotSpeed(context) })
-	apiGroup.POST("/robot/speed", func(context *gin.Context) { setRobotSpeed(context) })
-
-	apiGroup.POST("/robot/move/distance", func(context *gin.Context) { robotForwardDistance(context) })
-	apiGroup.POST("/robot/move/point", func(context *gin.Context) { robotForwardPoint(context) })
-
-	apiGroup.POST("/robot/rotate/relative", func(context *gin.Context) { robotRelativeRotation(context) })
-	apiGroup.POST("/robot/rotate/absolute", func(context *gin.Context) { robotAbsoluteRotation(context) })
-
-	apiGroup.POST("/robot/motors/stop", func(context *gin.Context) { sendStop(context) })
-	apiGroup.POST("/robot/st/align", func(context *gin.Context) { robotAlign(context) })
-	apiGroup.POST("/robot/st/starter", func(context *gin.Context) { robotStarterToggle(context) })
-
-	apiGroup.GET("/robot/battery", func(context *gin.Context) { getRobotBattery(context) })
-	apiGroup.GET("/robot/reset", func(context *gin.Context) { resetRobotcontext(context) })
+	for _, command := range commands {
+		apiGroup.Handle(command.Method, strings.TrimPrefix(command.Path, "/api"), restCommand(command.handler))
+	}
+	apiGroup.GET("/robot/stream", func(context *gin.Context) { ws.streamRobotEvents(context) })
 
 	//apiGroup.GET("/system", func(context *gin.Context) { getSystemInformation(context) })
 
@@ -103,6 +124,11 @@ func NewWebServer(robot *robot.Robot, address string, port int) *WebServer {
 
 	router.GET("/", func(context *gin.Context) { context.Redirect(http.StatusMovedPermanently, "/controller") })
 
+	ws.ServerSocketM.HandleConnect(ws.handleWebSocketConnect)
+	ws.ServerSocketM.HandleDisconnect(ws.handleWebSocketDisconnect)
+	ws.ServerSocketM.HandleMessage(ws.handleWebSocketMessage)
+	ws.handleSocketIO()
+
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -119,279 +145,577 @@ func (ws *WebServer) Start() {
 
 }
 
-func getRobotPosition(context *gin.Context) {
+func getRobotPosition(request commandRequest) commandReply {
 
-	postion := robotInstance.GetPosition()
-	context.JSON(http.StatusOK, postion)
+	position, err := robotInstance.ToFrame(request.query("frame"), robotInstance.GetPosition())
+	if err != nil {
+		return frameErrorReply(err)
+	}
+	return replyOK(position)
 }
 
-func getRobotBattery(context *gin.Context) {
-	time := robotInstance.TimerBattery
-	percent := (float64(time) / float64(1200.0))
-	context.JSON(http.StatusOK, percent)
+func getOtherRobotPosition(request commandRequest) commandReply {
+
+	position, ok := robotInstance.GetOtherPosition()
+	if !ok {
+		return commandReply{http.StatusNotFound, gin.H{"error": "opponent position not received"}}
+	}
+	position, err := robotInstance.ToFrame(request.query("frame"), position)
+	if err != nil {
+		return frameErrorReply(err)
+	}
+	return replyOK(position)
 }
 
-func resetRobotcontext(context *gin.Context) {
-	robotInstance.ResetBoard()
-	context.JSON(http.StatusOK, gin.H{"error": false})
+//frameErrorReply replies 400 for an unknown frame and 409 if the frame is not available yet
+func frameErrorReply(err error) commandReply {
+	if err == robot.ErrUnknownFrame {
+		return replyError(http.StatusBadRequest, err)
+	}
+	return replyError(http.StatusConflict, err)
 }
 
-func setRobotPosition(context *gin.Context) {
+func getStartPosition(request commandRequest) commandReply {
 
-	var position map[string]int16
-	//value, _ := c.Request.GetBody()
-	//fmt.Print(value)
+	position, ok := robotInstance.GetStartPosition()
+	if !ok {
+		return replyError(http.StatusNotFound, robot.ErrNoStartPosition)
+	}
+	return replyOK(position)
+}
 
-	err := context.ShouldBindJSON(&position)
-	if err == nil {
-		newPosition := models.Position{
-			X:     position["x"],
-			Y:     position["y"],
-			Angle: position["angle"],
-		}
-		robotInstance.SetPosition(newPosition)
-		context.JSON(http.StatusOK, gin.H{"error": false})
+func setStartPosition(request commandRequest) commandReply {
 
-	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+	//without a payload the current position becomes the start pose
+	if !request.hasData() {
+		robotInstance.SetStartPosition(robotInstance.GetPosition())
+		return replyOK(robotInstance.GetPosition())
+	}
+
+	var payload models.PositionPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
 	}
+	position := models.Position{X: payload.X, Y: payload.Y, Angle: payload.Angle}
+	robotInstance.SetStartPosition(position)
+	return replyOK(position)
 }
 
-func sendStop(context *gin.Context) {
+//waypointFromRequest converts a waypoint of the request frame (?frame=) to the field frame
+func waypointFromRequest(request commandRequest, waypoint models.Waypoint) (models.Waypoint, error) {
+	position := models.Position{X: waypoint.X, Y: waypoint.Y}
+	if waypoint.Angle != nil {
+		position.Angle = *waypoint.Angle
+	}
+	position, err := robotInstance.FromFrame(request.query("frame"), position)
+	waypoint.X, waypoint.Y = position.X, position.Y
+	if waypoint.Angle != nil {
+		waypoint.Angle = &position.Angle
+	}
+	return waypoint, err
+}
 
-	robotInstance.StopMotors()
-	context.JSON(http.StatusOK, gin.H{"error": false})
+func getRobotColor(request commandRequest) commandReply {
+	return replyOK(gin.H{"color": robotInstance.GetColor(), "mirrored": robotInstance.Mirrored()})
 }
 
-func getRobotSpeed(context *gin.Context) {
+func setRobotColor(request commandRequest) commandReply {
 
-	speed := robotInstance.Speed
-	context.JSON(http.StatusOK, gin.H{"speed": speed})
+	var payload models.ColorPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	robotInstance.SetColor(payload.Color)
+	return replyOK(gin.H{"color": robotInstance.GetColor(), "mirrored": robotInstance.Mirrored()})
 }
 
-func setRobotSpeed(context *gin.Context) {
-	var json map[string]int16
-	//value, _ := c.Request.GetBody()
-	//fmt.Print(value)
+func getRobotState(request commandRequest) commandReply {
+	return replyOK(robotInstance.Snapshot())
+}
 
-	err := context.ShouldBindJSON(&json)
-	if err == nil {
-		newSpeed := json["speed"]
-		errRobot := robotInstance.SetSpeed(newSpeed)
-		if errRobot != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err})
-		} else {
-			context.JSON(http.StatusOK, gin.H{"error": false})
-		}
+func getRobotStatus(request commandRequest) commandReply {
+	return replyOK(robotInstance.GetStatus())
+}
+
+func getRobotObstacles(request commandRequest) commandReply {
+
+	if request.query("all") == "true" {
+		return replyOK(robotInstance.Obstacles.AllObstacles())
+	}
+	return replyOK(robotInstance.Obstacles.Obstacles())
+}
+
+//getRobotBattery returns the battery charge as a fraction (1 is a full pack)
+func getRobotBattery(request commandRequest) commandReply {
+	battery := robotInstance.GetBattery()
+	return replyOK(battery.Percent / 100)
+}
+
+func getRobotBatteryStatus(request commandRequest) commandReply {
+	return replyOK(robotInstance.GetBattery())
+}
+
+func resetRobotBattery(request commandRequest) commandReply {
+	return replyOK(robotInstance.ResetBatteryTimer())
+}
 
+func getRobotSignals(request commandRequest) commandReply {
+	return replyOK(robotInstance.GetSignals())
+}
+
+func getRobotMessageSignals(request commandRequest) commandReply {
+	message, ok := robotInstance.GetMessageSignals(request.query("message"))
+	if !ok {
+		return commandReply{http.StatusNotFound, gin.H{"error": "no signals received for message " + request.query("message")}}
+	}
+	return replyOK(message)
+}
+
+func getConnectionStats(request commandRequest) commandReply {
+	return replyOK(robotInstance.Connection.Stats())
+}
+
+func getCanRecorder(request commandRequest) commandReply {
+	return replyOK(robotInstance.Connection.Recorder.Status())
+}
+
+func toggleCanRecorder(request commandRequest) commandReply {
+
+	var payload models.EnablePayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+
+	recorder := robotInstance.Connection.Recorder
+	var errRecorder error
+	if payload.Enable {
+		errRecorder = recorder.Start()
 	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+		errRecorder = recorder.Stop()
 	}
+	if errRecorder != nil {
+		return replyError(http.StatusInternalServerError, errRecorder)
+	}
+	return replyOK(recorder.Status())
 }
 
-func robotForwardDistance(context *gin.Context) {
+func getReplayStatus(request commandRequest) commandReply {
+	replay, ok := robotInstance.Connection.Transport.(*robot.ReplayTransport)
+	if !ok {
+		return commandReply{http.StatusNotFound, gin.H{"error": "the robot is not running a replay"}}
+	}
+	return replyOK(replay.Status())
+}
 
-	var json map[string]int16
-	//value, _ := c.Request.GetBody()
-	//fmt.Print(value)
+func replayStep(request commandRequest) commandReply {
+	replay, ok := robotInstance.Connection.Transport.(*robot.ReplayTransport)
+	if !ok {
+		return commandReply{http.StatusNotFound, gin.H{"error": "the robot is not running a replay"}}
+	}
 
-	err := context.ShouldBindJSON(&json)
-	if err == nil {
-		distance := json["distance"]
-		errRobot := robotInstance.ForwardDistance(distance)
-		if errRobot != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err})
-		} else {
-			context.JSON(http.StatusOK, gin.H{"error": false})
+	//without a payload a single frame is replayed
+	payload := models.ReplayStepPayload{Frames: 1}
+	if request.hasData() {
+		if err := request.bind(&payload); err != nil {
+			return replyError(http.StatusBadRequest, err)
 		}
+	}
+	if errReplay := replay.Step(payload.Frames); errReplay != nil {
+		return replyError(http.StatusBadRequest, errReplay)
+	}
+	return replyOK(replay.Status())
+}
 
-	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+func resetRobotBoard(request commandRequest) commandReply {
+	robotInstance.ResetBoard()
+	return replyOK(gin.H{"error": false})
+}
+
+func setRobotPosition(request commandRequest) commandReply {
+
+	var payload models.PositionPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	position, err := robotInstance.FromFrame(request.query("frame"), models.Position{X: payload.X, Y: payload.Y, Angle: payload.Angle})
+	if err != nil {
+		return frameErrorReply(err)
 	}
+	handle, errRobot := robotInstance.SetPosition(position)
+	return motionReply(request, handle, errRobot)
+}
+
+func sendStop(request commandRequest) commandReply {
 
+	handle, errRobot := robotInstance.StopMotors()
+	return motionReply(request, handle, errRobot)
 }
 
-func robotAlign(context *gin.Context) {
+func sendBrake(request commandRequest) commandReply {
 
-	var json map[string]int16
-	//value, _ := c.Request.GetBody()
-	//fmt.Print(value)
+	handle, errRobot := robotInstance.Brake()
+	return motionReply(request, handle, errRobot)
+}
 
-	err := context.ShouldBindJSON(&json)
-	if err == nil {
-		errRobot := robotInstance.Align(uint8(json["color"]))
-		if errRobot != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": errRobot})
-		} else {
-			context.JSON(http.StatusOK, gin.H{"error": false})
-		}
-	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+func getRobotSpeed(request commandRequest) commandReply {
+
+	speed := robotInstance.GetSpeed()
+	return replyOK(gin.H{"speed": speed})
+}
+
+func setRobotSpeed(request commandRequest) commandReply {
+
+	var payload models.SpeedPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	if errRobot := robotInstance.SetSpeed(payload.Speed); errRobot != nil {
+		return replyError(http.StatusInternalServerError, errRobot)
 	}
+	return replyOK(gin.H{"error": false})
+}
 
+func robotForwardDistance(request commandRequest) commandReply {
+
+	var payload models.DistancePayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	handle, errRobot := robotInstance.ForwardDistance(payload.Distance)
+	return motionReply(request, handle, errRobot)
 }
 
-func robotStarterToggle(context *gin.Context) {
+func robotAlign(request commandRequest) commandReply {
 
-	var json map[string]interface{}
-	//value, _ := c.Request.GetBody()
-	//fmt.Print(value)
+	var payload models.ColorPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	if errRobot := robotInstance.Align(payload.Color); errRobot != nil {
+		return replyError(http.StatusInternalServerError, errRobot)
+	}
+	return replyOK(gin.H{"error": false})
+}
 
-	err := context.BindJSON(&json)
-	if err == nil {
-		var enb bool = json["enable"].(bool)
-		errRobot := robotInstance.ToggleStarter(enb)
-		if errRobot != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": errRobot})
-		} else {
-			context.JSON(http.StatusOK, gin.H{"error": false})
-		}
-	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+func robotStarterToggle(request commandRequest) commandReply {
+
+	var payload models.EnablePayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
 	}
+	if errRobot := robotInstance.ToggleStarter(payload.Enable); errRobot != nil {
+		return replyError(http.StatusInternalServerError, errRobot)
+	}
+	return replyOK(gin.H{"error": false})
+}
+
+func robotForwardPoint(request commandRequest) commandReply {
+
+	var payload models.PositionPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	point, err := robotInstance.FromFrame(request.query("frame"), models.Position{X: payload.X, Y: payload.Y})
+	if err != nil {
+		return frameErrorReply(err)
+	}
+	handle, errRobot := robotInstance.ForwardToPoint(point.X, point.Y)
+	return motionReply(request, handle, errRobot)
+}
+
+func getRobotGoal(request commandRequest) commandReply {
+
+	goal, ok := robotInstance.GetGoal()
+	if !ok {
+		return commandReply{http.StatusNotFound, gin.H{"error": "no motion goal"}}
+	}
+	return replyOK(goal)
 }
 
-func robotForwardPoint(context *gin.Context) {
+func getRobotCommands(request commandRequest) commandReply {
+	return replyOK(robotInstance.GetCommands())
+}
 
-	var json map[string]int16
-	//value, _ := c.Request.GetBody()
-	//fmt.Print(value)
+func getRobotCommand(request commandRequest) commandReply {
 
-	err := context.ShouldBindJSON(&json)
-	if err == nil {
-		errRobot := robotInstance.ForwardToPoint(json["x"], json["y"])
-		if errRobot != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err})
-		} else {
-			context.JSON(http.StatusOK, gin.H{"error": false})
+	id, err := strconv.ParseUint(request.query("id"), 10, 64)
+	if err != nil {
+		return commandReply{http.StatusBadRequest, gin.H{"error": "invalid command id"}}
+	}
+	handle, ok := robotInstance.GetCommand(id)
+	if !ok {
+		return commandReply{http.StatusNotFound, gin.H{"error": "unknown command " + request.query("id")}}
+	}
+	waitMotion(request, handle)
+	return goalReply(handle.Goal())
+}
+
+//motionReply replies to a motion request with the command handle.
+//With ?wait=true the reply is sent when the command is finished (or after ?timeout= seconds).
+func motionReply(request commandRequest, handle *robot.MotionHandle, errRobot error) commandReply {
+
+	if errRobot != nil {
+		response := gin.H{"error": errRobot.Error()}
+		if handle != nil {
+			response["goal"] = handle.Goal()
 		}
+		switch {
+		case errors.Is(errRobot, robot.ErrGeofence):
+			return commandReply{http.StatusUnprocessableEntity, response}
+		case errors.Is(errRobot, robot.ErrMatchFinished):
+			return commandReply{http.StatusConflict, response}
+		default:
+			return commandReply{http.StatusInternalServerError, response}
+		}
+	}
+	waitMotion(request, handle)
+	return goalReply(handle.Goal())
+}
 
-	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+//waitMotion blocks until the command is finished if the request asks for it
+func waitMotion(request commandRequest, handle *robot.MotionHandle) {
+
+	if request.query("wait") != "true" {
+		return
 	}
+	timeout := robot.GOAL_TIMEOUT
+	if seconds, err := strconv.ParseFloat(request.query("timeout"), 64); err == nil && seconds > 0 {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	select {
+	case <-handle.Done():
+	case <-time.After(timeout):
+	case <-request.context.Done():
+	}
+}
+
+//goalReply replies with the state of a command: 202 while running, 200 when reached, 409 when failed
+func goalReply(goal models.MotionGoal) commandReply {
 
+	switch {
+	case goal.Active():
+		return commandReply{http.StatusAccepted, gin.H{"error": false, "goal": goal}}
+	case goal.State == models.GOAL_REACHED:
+		return replyOK(gin.H{"error": false, "goal": goal})
+	default:
+		return commandReply{http.StatusConflict, gin.H{"error": goal.Error, "goal": goal}}
+	}
 }
 
-func robotRelativeRotation(context *gin.Context) {
+func getRobotPath(request commandRequest) commandReply {
+	return replyOK(robotInstance.Path.Status())
+}
 
-	var json map[string]int16
+func startRobotPath(request commandRequest) commandReply {
 
-	err := context.ShouldBindJSON(&json)
-	if err == nil {
-		errRobot := robotInstance.RelativeRotation(json["angle"])
-		if errRobot != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err})
-		} else {
-			context.JSON(http.StatusOK, gin.H{"error": false})
+	var payload models.PathPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	for i := range payload.Waypoints {
+		var err error
+		if payload.Waypoints[i], err = waypointFromRequest(request, payload.Waypoints[i]); err != nil {
+			return frameErrorReply(err)
 		}
+	}
+	errPath := robotInstance.Path.Start(payload.Waypoints)
+	switch {
+	case errPath == nil:
+		return replyOK(robotInstance.Path.Status())
+	case errPath == robot.ErrPathActive, errPath == robot.ErrMatchFinished:
+		return replyError(http.StatusConflict, errPath)
+	case errors.Is(errPath, robot.ErrGeofence):
+		return replyError(http.StatusUnprocessableEntity, errPath)
+	default:
+		return replyError(http.StatusBadRequest, errPath)
+	}
+}
 
-	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+//controlRobotPath returns the handler executing the path action (pause, resume or cancel)
+func controlRobotPath(action func(path *robot.PathExecutor) error) commandHandler {
+	return func(request commandRequest) commandReply {
+
+		if errPath := action(robotInstance.Path); errPath != nil {
+			return replyError(http.StatusConflict, errPath)
+		}
+		return replyOK(robotInstance.Path.Status())
 	}
+}
 
+func getRobotField(request commandRequest) commandReply {
+	return replyOK(robotInstance.GetField())
 }
 
-func robotAbsoluteRotation(context *gin.Context) {
+func getRobotPlan(request commandRequest) commandReply {
+	return replyOK(robotInstance.Planner.Status())
+}
 
-	var json map[string]int16
+func startRobotPlan(request commandRequest) commandReply {
 
-	err := context.ShouldBindJSON(&json)
-	if err == nil {
-		errRobot := robotInstance.AbsoluteRotation(json["angle"])
-		if errRobot != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err})
-		} else {
-			context.JSON(http.StatusOK, gin.H{"error": false})
+	var goal models.Waypoint
+	if err := request.bind(&goal); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	goal, err := waypointFromRequest(request, goal)
+	if err != nil {
+		return frameErrorReply(err)
+	}
+
+	//with ?dry=true the path is only computed
+	if request.query("dry") == "true" {
+		waypoints, errPlan := robotInstance.Planner.Plan(goal)
+		if errPlan != nil {
+			return replyError(http.StatusUnprocessableEntity, errPlan)
 		}
+		return replyOK(gin.H{"error": false, "waypoints": waypoints})
+	}
 
-	} else {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err})
+	errPlan := robotInstance.Planner.Start(goal)
+	switch errPlan {
+	case nil:
+		return replyOK(robotInstance.Planner.Status())
+	case robot.ErrPlanActive, robot.ErrPathActive, robot.ErrMatchFinished:
+		return replyError(http.StatusConflict, errPlan)
+	default:
+		return replyError(http.StatusUnprocessableEntity, errPlan)
 	}
+}
 
+func cancelRobotPlan(request commandRequest) commandReply {
+
+	if errPlan := robotInstance.Planner.Cancel(); errPlan != nil {
+		return replyError(http.StatusConflict, errPlan)
+	}
+	return replyOK(gin.H{"error": false})
 }
 
-func newServerSocket() *socketio.Server {
+func getMatch(request commandRequest) commandReply {
+	return replyOK(robotInstance.Match.Status())
+}
 
-	serverSocket := socketio.NewServer(nil)
+func startMatch(request commandRequest) commandReply {
 
-	serverSocket.OnConnect("/", func(s socketio.Conn) error {
-		s.Join("robot_controller")
-		s.SetContext("")
-		return nil
-	})
+	if errMatch := robotInstance.Match.Start(); errMatch != nil {
+		return replyError(http.StatusConflict, errMatch)
+	}
+	return replyOK(robotInstance.Match.Status())
+}
 
-	serverSocket.OnEvent("/", "notice", func(s socketio.Conn, msg string) {
-		fmt.Println("notice:", msg)
-		s.Emit("reply", "have "+msg)
-	})
+func resetMatch(request commandRequest) commandReply {
+	robotInstance.Match.Reset()
+	return replyOK(robotInstance.Match.Status())
+}
 
-	serverSocket.OnError("/", func(s socketio.Conn, e error) {
-		fmt.Println("meet error:", e)
-	})
+func robotRelativeRotation(request commandRequest) commandReply {
 
-	serverSocket.OnDisconnect("/", func(s socketio.Conn, reason string) {
+	var payload models.RotationPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	degree, errFrame := robotInstance.FromFrameRotation(request.query("frame"), payload.Angle)
+	if errFrame != nil {
+		return frameErrorReply(errFrame)
+	}
+	handle, errRobot := robotInstance.RelativeRotation(degree)
+	return motionReply(request, handle, errRobot)
+}
+
+func robotAbsoluteRotation(request commandRequest) commandReply {
+
+	var payload models.RotationPayload
+	if err := request.bind(&payload); err != nil {
+		return replyError(http.StatusBadRequest, err)
+	}
+	heading, err := robotInstance.FromFrame(request.query("frame"), models.Position{Angle: payload.Angle})
+	if err != nil {
+		return frameErrorReply(err)
+	}
+	handle, errRobot := robotInstance.AbsoluteRotation(heading.Angle)
+	return motionReply(request, handle, errRobot)
+}
+
+//handleWebSocketConnect connects the session to the publisher,
+//the positions are sent in the frame selected by the client (/ws?frame=)
+//and the topics can be selected when connecting (/ws?topics=position,status)
+func (ws *WebServer) handleWebSocketConnect(s *melody.Session) {
+
+	log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Client connected!")
+
+	frame := s.Request.URL.Query().Get("frame")
+	if _, err := robotInstance.ToFrame(frame, models.Position{}); err == robot.ErrUnknownFrame {
+		message, _ := json.Marshal(models.WebSocketResponse{Command: WS_REPLY_ERROR, Data: models.WebSocketReply{Status: http.StatusBadRequest, Error: err.Error()}})
+		s.Write(message)
 		s.Close()
-		//fmt.Println("closed", reason)
-		//fmt.Println("connected remain:", serverSocket.Count())
-	})
+		return
+	}
 
-	return serverSocket
-}
-
-//NewMelodyWebSocket return melody server
-func NewMelodyWebSocket() *melody.Melody {
-
-	server := melody.New()
-
-	server.HandleConnect(func(s *melody.Session) {
-
-		log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Client connected!")
-
-		go func() {
-			for {
-				if s.IsClosed() {
-					//log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Session closed!")
-					return
-				}
-				position := robotInstance.GetPosition()
-				wsMessage := models.WebSocketMessage{
-					Command: "position",
-					Payload: position,
-				}
-				message, err := json.Marshal(wsMessage)
-				if err == nil {
-					s.Write(message)
-					//log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Position sent!")
-				}
-				time.Sleep(time.Millisecond * 20)
-			}
-		}()
+	topics := DEFAULT_TOPICS
+	if query := s.Request.URL.Query().Get("topics"); query != "" {
+		topics = strings.Split(query, ",")
+	}
+	ws.Publisher.Add(s, frame, true, func(message models.WebSocketMessage) {
+		data, err := json.Marshal(message)
+		if err == nil {
+			s.Write(data)
+		}
 	})
+	if _, err := ws.Publisher.Subscribe(s, topicOptions(topics)); err != nil {
+		message, _ := json.Marshal(models.WebSocketResponse{Command: WS_REPLY_ERROR, Data: models.WebSocketReply{Status: http.StatusBadRequest, Error: err.Error()}})
+		s.Write(message)
+		s.Close()
+	}
+}
 
-	server.HandleDisconnect(func(s *melody.Session) {
-		log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Client disconnected!")
-	})
+//handleWebSocketDisconnect removes the session from the publisher
+func (ws *WebServer) handleWebSocketDisconnect(s *melody.Session) {
+	ws.Publisher.Remove(s)
+	log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Client disconnected!")
+}
+
+//handleWebSocketMessage executes the command sent by the client and replies only to it
+func (ws *WebServer) handleWebSocketMessage(s *melody.Session, msg []byte) {
+	message := models.WebSocketRequest{}
+	err := json.Unmarshal([]byte(msg), &message)
+	if err != nil {
+		log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiRed), err)
+		reply, _ := json.Marshal(webSocketError(message, http.StatusBadRequest, "invalid message format"))
+		s.Write(reply)
+		return
+	}
+	log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Client sent command: "+message.Command)
 
-	server.HandleMessage(func(s *melody.Session, msg []byte) {
-		message := models.WebSocketMessage{}
-		err := json.Unmarshal([]byte(msg), &message)
+	//the commands use the frame of the session unless another one is requested
+	if frame := s.Request.URL.Query().Get("frame"); frame != "" {
+		if message.Query == nil {
+			message.Query = map[string]string{}
+		}
+		if _, ok := message.Query["frame"]; !ok {
+			message.Query["frame"] = frame
+		}
+	}
+
+	reply := func() {
+		reply, err := json.Marshal(ws.ManageWebSocketMessages(s, message))
 		if err != nil {
-			s.Write([]byte("Error : Message Format"))
 			log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiRed), err)
-		} else {
-			log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiMagenta), "Client sent command: "+message.Command)
-			ManageWebSocketMessages(message)
-			server.Broadcast([]byte(message.Command))
+			return
 		}
-
-	})
-
-	return server
+		s.Write(reply)
+	}
+	//the subscriptions are changed in order, the other commands can wait for the motion
+	//so they are executed without blocking the session
+	switch message.Command {
+	case WS_COMMAND_SUBSCRIBE, WS_COMMAND_UNSUBSCRIBE, WS_COMMAND_SUBSCRIPTIONS:
+		reply()
+	default:
+		go reply()
+	}
 }
 
-//ManageWebSocketMessages manage the websocket and socket.io messages
-func ManageWebSocketMessages(msg models.WebSocketMessage) {
-
+//broadcastMessage sends the message to every client
+func (ws *WebServer) broadcastMessage(command string, payload interface{}) {
+	ws.Publisher.Broadcast(command, payload)
 }
 
 //GinMiddleware manage the cors
@@ -0,0 +1,146 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/arslab/robot_controller/models"
+	"github.com/arslab/robot_controller/utilities"
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+//WS_REPLY_ACK and WS_REPLY_ERROR are the commands of the replies to the websocket requests
+const (
+	WS_REPLY_ACK   = "ack"
+	WS_REPLY_ERROR = "error"
+)
+
+//websocket commands that are not REST operations
+const (
+	WS_COMMAND_HELP          = "help"
+	WS_COMMAND_SUBSCRIBE     = "subscribe"
+	WS_COMMAND_UNSUBSCRIBE   = "unsubscribe"
+	WS_COMMAND_SUBSCRIPTIONS = "subscriptions"
+)
+
+//ManageWebSocketMessages manage the websocket and socket.io messages,
+//it executes the command of the client (its publisher key) and returns the reply
+func (ws *WebServer) ManageWebSocketMessages(client interface{}, msg models.WebSocketRequest) models.WebSocketResponse {
+
+	switch msg.Command {
+	case WS_COMMAND_SUBSCRIBE, WS_COMMAND_UNSUBSCRIBE, WS_COMMAND_SUBSCRIPTIONS:
+		return ws.manageSubscriptions(client, msg)
+	case WS_COMMAND_HELP:
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		result := make([]gin.H, len(names))
+		for i, name := range names {
+			result[i] = gin.H{"command": name, "method": commands[name].Method, "path": commands[name].Path}
+		}
+		return webSocketAck(msg, http.StatusOK, gin.H{
+			"commands": result,
+			"topics":   ws.Publisher.Topics(),
+		})
+	}
+
+	command, ok := commands[msg.Command]
+	if !ok {
+		return webSocketError(msg, http.StatusNotFound, "unknown command: "+msg.Command)
+	}
+
+	//the path parameters are required, like in the REST route
+	for _, segment := range strings.Split(command.Path, "/") {
+		if strings.HasPrefix(segment, ":") && msg.Query[segment[1:]] == "" {
+			return webSocketError(msg, http.StatusBadRequest, "missing parameter: "+segment[1:])
+		}
+	}
+
+	reply := command.handler(commandRequest{params: msg.Query, data: msg.Data, context: context.Background()})
+	if reply.status >= http.StatusBadRequest {
+		message := http.StatusText(reply.status)
+		if body, ok := reply.body.(gin.H); ok {
+			if text, ok := body["error"].(string); ok {
+				message = text
+			}
+		}
+		response := webSocketError(msg, reply.status, message)
+		response.Data.Result = reply.body
+		return response
+	}
+	return webSocketAck(msg, reply.status, reply.body)
+}
+
+//manageSubscriptions changes the topics subscribed by the client,
+//the topics are given with their options ({"position": {"interval": 100}}) or as a list (["position"])
+func (ws *WebServer) manageSubscriptions(client interface{}, msg models.WebSocketRequest) models.WebSocketResponse {
+
+	topics := map[string]models.TopicOptions{}
+	if request := (commandRequest{data: msg.Data}); request.hasData() {
+		if err := request.bind(&topics); err != nil {
+			var names []string
+			if err = request.bind(&names); err != nil {
+				return webSocketError(msg, http.StatusBadRequest, "invalid topics format")
+			}
+			topics = topicOptions(names)
+		}
+	}
+
+	var subscriptions map[string]models.TopicOptions
+	var err error
+	switch msg.Command {
+	case WS_COMMAND_SUBSCRIBE:
+		subscriptions, err = ws.Publisher.Subscribe(client, topics)
+	case WS_COMMAND_UNSUBSCRIBE:
+		names := make([]string, 0, len(topics))
+		for name := range topics {
+			names = append(names, name)
+		}
+		subscriptions, err = ws.Publisher.Unsubscribe(client, names)
+	default:
+		subscriptions, err = ws.Publisher.Subscriptions(client)
+	}
+	if errors.Is(err, ErrUnknownClient) {
+		return webSocketError(msg, http.StatusConflict, err.Error())
+	} else if err != nil {
+		return webSocketError(msg, http.StatusBadRequest, err.Error())
+	}
+	return webSocketAck(msg, http.StatusOK, subscriptions)
+}
+
+//topicOptions returns the topics with the default options
+func topicOptions(names []string) map[string]models.TopicOptions {
+
+	topics := map[string]models.TopicOptions{}
+	for _, name := range names {
+		topics[strings.TrimSpace(name)] = models.TopicOptions{}
+	}
+	return topics
+}
+
+//webSocketAck returns the reply to the websocket request with the result of the command
+func webSocketAck(msg models.WebSocketRequest, status int, result interface{}) models.WebSocketResponse {
+	return models.WebSocketResponse{
+		Command: WS_REPLY_ACK,
+		ID:      msg.ID,
+		Data:    models.WebSocketReply{Status: status, Command: msg.Command, Result: result},
+	}
+}
+
+//webSocketError returns the error reply to the websocket request
+func webSocketError(msg models.WebSocketRequest, status int, message string) models.WebSocketResponse {
+
+	log.Printf("[%s] %s", utilities.CreateColorString("WEB SOCKET", color.FgHiRed), msg.Command+": "+message)
+	return models.WebSocketResponse{
+		Command: WS_REPLY_ERROR,
+		ID:      msg.ID,
+		Data:    models.WebSocketReply{Status: status, Command: msg.Command, Error: message},
+	}
+}